@@ -0,0 +1,179 @@
+package viesapi
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Audit log entry does not continue the hash chain
+var ErrAuditChain = errors.New("viesapi: audit log hash chain broken")
+
+// Single verification recorded in the audit trail
+type AuditRecord struct {
+	Seq      int64      `json:"seq"`
+	Time     time.Time  `json:"time"`
+	Number   string     `json:"number"`
+	Data     *VIESData  `json:"data,omitempty"`
+	Error    *ViesError `json:"error,omitempty"`
+	ID       string     `json:"id,omitempty"`
+	UID      string     `json:"uid,omitempty"`
+	Source   string     `json:"source,omitempty"`
+	Cached   bool       `json:"cached,omitempty"`
+	PrevHash string     `json:"prev_hash"`
+	Hash     string     `json:"hash"`
+}
+
+// AuditSink receives a record of every VIES data lookup
+type AuditSink interface {
+	Record(rec *AuditRecord) error
+}
+
+// Record lookup in the audit sink, failures are logged and do not fail the lookup
+func (c *VIESClient) audit(ctx context.Context, number string, cached bool, data *VIESData, e *ViesError) {
+	if c.auditor == nil {
+		return
+	}
+
+	rec := &AuditRecord{
+		Time:   c.clock(),
+		Number: number,
+		Data:   data,
+		Error:  e,
+		Cached: cached,
+	}
+	if data != nil {
+		rec.ID = data.ID
+		rec.UID = data.UID
+		rec.Source = data.Source
+	}
+
+	if err := c.auditor.Record(rec); err != nil && c.logger != nil {
+		c.logger.LogAttrs(ctx, slog.LevelError, "viesapi audit failed", slog.String("error", err.Error()))
+	}
+}
+
+// Append-only JSON Lines audit log with hash-chained entries
+type AuditLog struct {
+	mu   sync.Mutex
+	f    *os.File
+	seq  int64
+	hash string
+}
+
+// Open audit log at specified path, creating it if needed and continuing its hash chain
+func OpenAuditLog(path string) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &AuditLog{f: f}
+	last, err := verifyAuditLog(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if last != nil {
+		l.seq = last.Seq
+		l.hash = last.Hash
+	}
+	return l, nil
+}
+
+// Append record to the log, filling its sequence number and hashes
+func (l *AuditLog) Record(rec *AuditRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r := *rec
+	r.Seq = l.seq + 1
+	r.PrevHash = l.hash
+	r.Hash = ""
+	hash, err := auditHash(&r)
+	if err != nil {
+		return err
+	}
+	r.Hash = hash
+
+	b, err := json.Marshal(&r)
+	if err != nil {
+		return err
+	}
+	if _, err := l.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	if err := l.f.Sync(); err != nil {
+		return err
+	}
+
+	l.seq = r.Seq
+	l.hash = r.Hash
+	return nil
+}
+
+// Close the log file
+func (l *AuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
+
+// Verify hash chain of the audit log read from r and return number of entries
+func VerifyAuditLog(r io.Reader) (int64, error) {
+	last, err := verifyAuditLog(r)
+	if last == nil {
+		return 0, err
+	}
+	return last.Seq, err
+}
+
+// Verify hash chain and return the last valid record
+func verifyAuditLog(r io.Reader) (*AuditRecord, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var last *AuditRecord
+	for line := 1; sc.Scan(); line++ {
+		var rec AuditRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return last, fmt.Errorf("%w: line %d: %v", ErrAuditChain, line, err)
+		}
+
+		prev := ""
+		if last != nil {
+			prev = last.Hash
+		}
+		if rec.Seq != int64(line) || rec.PrevHash != prev {
+			return last, fmt.Errorf("%w: line %d: out of sequence", ErrAuditChain, line)
+		}
+
+		hash := rec.Hash
+		rec.Hash = ""
+		if h, err := auditHash(&rec); err != nil || h != hash {
+			return last, fmt.Errorf("%w: line %d: hash mismatch", ErrAuditChain, line)
+		}
+		rec.Hash = hash
+		last = &rec
+	}
+	return last, sc.Err()
+}
+
+// Calculate hash of the record with empty Hash field
+func auditHash(rec *AuditRecord) (string, error) {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
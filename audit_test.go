@@ -0,0 +1,89 @@
+package viesapi
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "DE123456789") {
+			w.Write([]byte(`<result><error><code>23</code><description>VIES sync error</description></error></result>`))
+			return
+		}
+		w.Write([]byte(`<result><vies><uid>test-uid</uid><countryCode>PL</countryCode><vatNumber>7272445205</vatNumber><valid>true</valid><traderName>Test Company</traderName><id>req-id</id><source>viesapi.eu</source><date>2024-01-15</date></vies><error><code>0</code></error></result>`))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatalf("OpenAuditLog returned error: %v", err)
+	}
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	c := NewVIESClient("test_id", "test_key", WithAudit(log), WithClock(func() time.Time { return now }))
+	c.SetUrl(server.URL)
+
+	c.GetVIESData("PL 7272445205")
+	c.GetVIESData("DE123456789")
+	c.GetVIESData("invalid")
+	log.Close()
+
+	b, _ := os.ReadFile(path)
+	n, err := VerifyAuditLog(bytes.NewReader(b))
+	if err != nil || n != 3 {
+		t.Fatalf("VerifyAuditLog = %d, %v; want 3, nil", n, err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	for i, want := range []string{
+		`"number":"PL7272445205"`,
+		`"error":{"code":23`,
+		`"number":"invalid"`,
+	} {
+		if !strings.Contains(lines[i], want) {
+			t.Errorf("line %d = %s, want %s", i+1, lines[i], want)
+		}
+	}
+	if !strings.Contains(lines[0], `"id":"req-id","uid":"test-uid","source":"viesapi.eu"`) {
+		t.Errorf("line 1 = %s, missing id, uid and source", lines[0])
+	}
+
+	// reopening continues the chain
+	log, err = OpenAuditLog(path)
+	if err != nil {
+		t.Fatalf("OpenAuditLog reopen returned error: %v", err)
+	}
+	c = NewVIESClient("test_id", "test_key", WithAudit(log))
+	c.SetUrl(server.URL)
+	c.GetVIESData("PL7272445205")
+	log.Close()
+
+	b, _ = os.ReadFile(path)
+	if n, err := VerifyAuditLog(bytes.NewReader(b)); err != nil || n != 4 {
+		t.Fatalf("VerifyAuditLog after reopen = %d, %v; want 4, nil", n, err)
+	}
+
+	// tampering is detected
+	tampered := strings.Replace(string(b), "Test Company", "Other Company", 1)
+	if _, err := VerifyAuditLog(strings.NewReader(tampered)); !errors.Is(err, ErrAuditChain) {
+		t.Errorf("VerifyAuditLog tampered = %v, want ErrAuditChain", err)
+	}
+	lines = strings.Split(string(b), "\n")
+	removed := strings.Join(append(lines[:1], lines[2:]...), "\n")
+	if n, err := VerifyAuditLog(strings.NewReader(removed)); !errors.Is(err, ErrAuditChain) || n != 1 {
+		t.Errorf("VerifyAuditLog removed line = %d, %v; want 1, ErrAuditChain", n, err)
+	}
+
+	os.WriteFile(path, []byte(tampered), 0o600)
+	if _, err := OpenAuditLog(path); !errors.Is(err, ErrAuditChain) {
+		t.Errorf("OpenAuditLog tampered = %v, want ErrAuditChain", err)
+	}
+}
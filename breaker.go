@@ -0,0 +1,163 @@
+package viesapi
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Lookups for the country fail fast because its circuit is open
+var ErrCircuitOpen = errors.New("viesapi: circuit open for member state")
+
+// State of a country circuit
+type CircuitState int
+
+const (
+	// Lookups pass through
+	CircuitClosed CircuitState = iota
+	// Lookups fail fast until the next probe
+	CircuitOpen
+	// Single probe lookup is in flight, others fail fast
+	CircuitHalfOpen
+)
+
+// Return state name
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// State of a country circuit for dashboards
+type CircuitInfo struct {
+	Country   string
+	State     CircuitState
+	Failures  int       // consecutive member state errors
+	NextProbe time.Time // zero unless open
+}
+
+type circuit struct {
+	failures int
+	open     bool
+	probing  bool
+	next     time.Time
+}
+
+// CircuitBreaker stops lookups for a member state after repeated VIES_SYNC
+// errors, so that an outage in one country does not slow down the others
+type CircuitBreaker struct {
+	threshold int
+	interval  time.Duration
+	clock     func() time.Time
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+// Create new CircuitBreaker opening a country circuit after threshold
+// consecutive member state errors and probing it every interval while open
+func NewCircuitBreaker(threshold int, interval time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		interval:  interval,
+		clock:     time.Now,
+		circuits:  make(map[string]*circuit),
+	}
+}
+
+// Get state of the country circuit
+func (b *CircuitBreaker) State(country string) CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.circuits[country].state()
+}
+
+// Get state of all country circuits that failed at least once, ordered by country
+func (b *CircuitBreaker) States() []CircuitInfo {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var states []CircuitInfo
+	for country, c := range b.circuits {
+		info := CircuitInfo{Country: country, State: c.state(), Failures: c.failures}
+		if c.open {
+			info.NextProbe = c.next
+		}
+		states = append(states, info)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Country < states[j].Country
+	})
+	return states
+}
+
+// Check if lookup for the country may proceed, letting a single probe through
+// once the open circuit is due
+func (b *CircuitBreaker) allow(country string) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuits[country]
+	switch {
+	case c == nil || !c.open:
+		return true
+	case c.probing || b.clock().Before(c.next):
+		return false
+	}
+	c.probing = true
+	return true
+}
+
+// Record result of the lookup for the country
+func (b *CircuitBreaker) record(country string, e *ViesError) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuits[country]
+	switch {
+	case e != nil && e.Code == VIES_SYNC:
+		if c == nil {
+			c = &circuit{}
+			b.circuits[country] = c
+		}
+		c.failures++
+		c.probing = false
+		if c.open || c.failures >= b.threshold {
+			c.open = true
+			c.next = b.clock().Add(b.interval)
+		}
+	case e == nil || e.Code == EUVAT_BAD:
+		// the member state answered, even if the number is invalid
+		delete(b.circuits, country)
+	default:
+		// the member state was not reached or the request was rejected before
+		// it, such as for invalid credentials, probe again on the next lookup
+		if c != nil {
+			c.probing = false
+		}
+	}
+}
+
+// Get state of the circuit
+func (c *circuit) state() CircuitState {
+	switch {
+	case c == nil || !c.open:
+		return CircuitClosed
+	case c.probing:
+		return CircuitHalfOpen
+	}
+	return CircuitOpen
+}
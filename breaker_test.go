@@ -0,0 +1,118 @@
+package viesapi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	down := map[string]bool{"IT": true}
+	hits := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		country := strings.TrimPrefix(r.URL.Path, "/get/vies/euvat/")[:2]
+		hits[country]++
+		if down[country] {
+			w.Write([]byte(`<result><error><code>23</code><description>Member state unavailable</description></error></result>`))
+			return
+		}
+		w.Write([]byte(`<result><vies><uid>test-uid</uid><countryCode>` + country + `</countryCode><vatNumber>1</vatNumber><valid>true</valid><date>2024-01-15</date></vies></result>`))
+	}))
+	defer server.Close()
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	b := NewCircuitBreaker(2, time.Minute)
+	b.clock = func() time.Time { return now }
+
+	c := NewVIESClient("test_id", "test_key", WithCircuitBreaker(b))
+	c.SetUrl(server.URL)
+
+	const it, de = "IT12345678901", "DE123456789"
+
+	for i := 0; i < 2; i++ {
+		if _, e := c.GetVIESData(it); e == nil || e.Code != VIES_SYNC {
+			t.Fatalf("lookup %d error = %v, want VIES_SYNC", i+1, e)
+		}
+	}
+	if s := b.State("IT"); s != CircuitOpen {
+		t.Fatalf("state = %v, want open", s)
+	}
+
+	// open circuit fails fast without affecting other countries
+	_, e := c.GetVIESData(it)
+	if e == nil || e.Code != CLI_CIRCUIT_OPEN || !errors.Is(e, ErrCircuitOpen) {
+		t.Errorf("error = %v, want CLI_CIRCUIT_OPEN", e)
+	}
+	if hits["IT"] != 2 {
+		t.Errorf("IT hits = %d, want 2", hits["IT"])
+	}
+	if _, e := c.GetVIESData(de); e != nil || b.State("DE") != CircuitClosed {
+		t.Errorf("DE lookup = %v, state %v; want success", e, b.State("DE"))
+	}
+
+	states := b.States()
+	if len(states) != 1 || states[0].Country != "IT" || states[0].Failures != 2 || !states[0].NextProbe.Equal(now.Add(time.Minute)) {
+		t.Errorf("States = %+v, want open IT", states)
+	}
+
+	// failed probe re-opens the circuit
+	now = now.Add(time.Minute)
+	c.GetVIESData(it)
+	if hits["IT"] != 3 || b.State("IT") != CircuitOpen {
+		t.Errorf("after failed probe hits = %d, state %v; want 3, open", hits["IT"], b.State("IT"))
+	}
+	c.GetVIESData(it)
+	if hits["IT"] != 3 {
+		t.Errorf("hits before next probe = %d, want 3", hits["IT"])
+	}
+
+	// successful probe closes it
+	now = now.Add(time.Minute)
+	down["IT"] = false
+	if _, e := c.GetVIESData(it); e != nil {
+		t.Errorf("probe error = %v, want success", e)
+	}
+	if b.State("IT") != CircuitClosed || len(b.States()) != 0 {
+		t.Errorf("state = %v, want closed", b.State("IT"))
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	b := NewCircuitBreaker(1, time.Minute)
+	b.clock = func() time.Time { return now }
+
+	b.record("IT", &ViesError{Code: VIES_SYNC})
+	if b.allow("IT") {
+		t.Error("open circuit allowed lookup")
+	}
+
+	now = now.Add(time.Minute)
+	if !b.allow("IT") {
+		t.Fatal("due circuit did not allow probe")
+	}
+	if b.State("IT") != CircuitHalfOpen || b.allow("IT") {
+		t.Errorf("state = %v, want half-open with single probe", b.State("IT"))
+	}
+
+	// probe not reaching the member state lets the next lookup probe again
+	b.record("IT", &ViesError{Code: CLI_CONNECT})
+	if b.State("IT") != CircuitOpen || !b.allow("IT") {
+		t.Errorf("state = %v, want open and due", b.State("IT"))
+	}
+
+	// rejected credentials say nothing about the member state
+	b.record("IT", &ViesError{Code: DB_AUTH_KEY_VALUE})
+	if b.State("IT") != CircuitOpen {
+		t.Errorf("state after auth failure = %v, want open", b.State("IT"))
+	}
+
+	// invalid number is an answer of the member state
+	b.record("IT", &ViesError{Code: EUVAT_BAD})
+	if b.State("IT") != CircuitClosed {
+		t.Errorf("state = %v, want closed", b.State("IT"))
+	}
+}
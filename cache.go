@@ -0,0 +1,79 @@
+package viesapi
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache stores VIES data results keyed by normalized EU VAT number
+type Cache interface {
+	// Get returns cached data and the time it was stored
+	Get(key string) (*VIESData, time.Time, bool)
+	// Set stores data under specified key
+	Set(key string, data *VIESData, at time.Time)
+}
+
+// In-memory least recently used cache
+type MemoryCache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key  string
+	data VIESData
+	at   time.Time
+}
+
+// Create new MemoryCache holding up to size entries (unbounded if size <= 0)
+func NewMemoryCache(size int) *MemoryCache {
+	return &MemoryCache{
+		size:  size,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// Get copy of cached data and the time it was stored
+func (m *MemoryCache) Get(key string) (*VIESData, time.Time, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.items[key]
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	m.order.MoveToFront(el)
+	entry := el.Value.(*cacheEntry)
+	data := entry.data
+	return &data, entry.at, true
+}
+
+// Store copy of data under specified key
+func (m *MemoryCache) Set(key string, data *VIESData, at time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.items[key]; ok {
+		el.Value = &cacheEntry{key: key, data: *data, at: at}
+		m.order.MoveToFront(el)
+		return
+	}
+	m.items[key] = m.order.PushFront(&cacheEntry{key: key, data: *data, at: at})
+
+	if m.size > 0 && m.order.Len() > m.size {
+		el := m.order.Back()
+		m.order.Remove(el)
+		delete(m.items, el.Value.(*cacheEntry).key)
+	}
+}
+
+// Get number of cached entries
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order.Len()
+}
@@ -0,0 +1,82 @@
+package viesapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache(t *testing.T) {
+	m := NewMemoryCache(2)
+	now := time.Now()
+
+	m.Set("A", &VIESData{UID: "a"}, now)
+	m.Set("B", &VIESData{UID: "b"}, now)
+	m.Get("A")
+	m.Set("C", &VIESData{UID: "c"}, now)
+
+	if _, _, ok := m.Get("B"); ok {
+		t.Error("least recently used entry was not evicted")
+	}
+	data, at, ok := m.Get("A")
+	if !ok || data.UID != "a" || !at.Equal(now) {
+		t.Errorf("Get(A) = %v, %v, %v; want a, %v, true", data, at, ok, now)
+	}
+	data.UID = "changed"
+	if data, _, _ := m.Get("A"); data.UID != "a" {
+		t.Error("cached entry modified through returned copy")
+	}
+	if m.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", m.Len())
+	}
+}
+
+func TestClientCache(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write([]byte(`<result><vies><uid>test-uid</uid><countryCode>PL</countryCode><valid>true</valid><vatNumber>7272445205</vatNumber><date>2024-01-15</date></vies><error><code>0</code></error></result>`))
+	}))
+	defer server.Close()
+
+	cache := NewMemoryCache(0)
+	c := NewVIESClient("test_id", "test_key", WithCache(cache, time.Minute))
+	c.SetUrl(server.URL)
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetVIESData("PL 727-244-52-05"); err != nil {
+			t.Fatalf("GetVIESData returned error: %v", err)
+		}
+	}
+	if hits != 1 {
+		t.Errorf("hits = %d, want 1", hits)
+	}
+
+	// expired entries are fetched again
+	cache.Set("PL7272445205", &VIESData{UID: "old"}, time.Now().Add(-time.Hour))
+	data, _ := c.GetVIESData("PL7272445205")
+	if hits != 2 || data.UID != "test-uid" {
+		t.Errorf("hits = %d, uid = %s; want 2, test-uid", hits, data.UID)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := &limiter{interval: 20 * time.Millisecond}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.wait(context.Background()); err != nil {
+			t.Fatalf("wait returned error: %v", err)
+		}
+	}
+	if d := time.Since(start); d < 40*time.Millisecond {
+		t.Errorf("3 requests took %v, want at least 40ms", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.wait(ctx); err == nil {
+		t.Error("wait should fail on cancelled context")
+	}
+}
@@ -0,0 +1,181 @@
+package viesapi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Page geometry of the certificate in PDF points (A4)
+const (
+	pdfWidth  = 595
+	pdfHeight = 842
+	pdfMargin = 56
+	pdfWrap   = 60 // max characters of a value line
+)
+
+// Write one-page PDF certificate of the VIES data result to w. Failures are
+// returned as *ViesError with DOCUMENT_PDF code.
+func (v *VIESData) WriteCertificate(w io.Writer) error {
+	if _, err := w.Write(v.certificate()); err != nil {
+		return &ViesError{Code: DOCUMENT_PDF, Description: "Failed to write PDF document", err: err}
+	}
+	return nil
+}
+
+// Render the certificate as complete PDF file
+func (v *VIESData) certificate() []byte {
+
+	status := "INVALID"
+	if v.Valid {
+		status = "VALID"
+	}
+
+	freshness := "Current"
+	switch {
+	case v.Degraded:
+		freshness = "Degraded - last known result, the lookup failed"
+	case v.Stale:
+		freshness = "Stale - cached result past its validity"
+	}
+
+	rows := [][2]string{
+		{"Trader name", v.TraderName},
+		{"Trader address", v.TraderAddress},
+		{"Company type", v.TraderCompanyType},
+		{"VAT number", v.CountryCode + v.VATNumber},
+		{"Status", status},
+		{"Check date", v.Date},
+		{"Freshness", freshness},
+		{"Request ID", v.ID},
+		{"UID", v.UID},
+		{"Source", v.Source},
+	}
+
+	var content bytes.Buffer
+	y := pdfHeight - pdfMargin - 18
+	pdfText(&content, "F2", 18, pdfMargin, y, "VIES Verification Certificate")
+	y -= 14
+	fmt.Fprintf(&content, "0.5 w %d %d m %d %d l S\n", pdfMargin, y, pdfWidth-pdfMargin, y)
+	y -= 30
+
+	for _, row := range rows {
+		pdfText(&content, "F2", 11, pdfMargin, y, row[0])
+		lines := wrapText(row[1], pdfWrap)
+		for i, line := range lines {
+			if i > 0 {
+				y -= 14
+			}
+			pdfText(&content, "F1", 11, pdfMargin+130, y, line)
+		}
+		y -= 22
+	}
+
+	pdfText(&content, "F1", 8, pdfMargin, pdfMargin,
+		"Data obtained from the EU VIES system. Identify the check by the request ID and UID shown above.")
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 4 0 R /F2 5 0 R >> >> /Contents 6 0 R >>", pdfWidth, pdfHeight),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+	}
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return b.Bytes()
+}
+
+// Write single line of text at specified position
+func pdfText(b *bytes.Buffer, font string, size, x, y int, s string) {
+	fmt.Fprintf(b, "BT /%s %d Tf %d %d Td (%s) Tj ET\n", font, size, x, y, pdfString(s))
+}
+
+// Encode text as WinAnsi PDF string literal content
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		c := winAnsi(r)
+		switch {
+		case c == '(' || c == ')' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20:
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// WinAnsi codes of characters outside of Latin-1
+var winAnsiExtra = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c, 'Ž': 0x8e,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9a, '›': 0x9b, 'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// Base letters of Latin Extended-A characters (U+0100 to U+017F)
+const latinExtendedA = "AaAaAaCcCcCcCcDdDdEeEeEeEeEeGgGgGgGgHhHhIiIiIiIiIiJjJjKkkLlLlLlLlLlNnNnNnnNnOoOoOoOoRrRrRrSsSsSsSsTtTtTtUuUuUuUuUuUuWwYyYZzZzZzs"
+
+// Get WinAnsi code of the rune, letters with diacritics missing from the
+// encoding are replaced by their base letter and anything else by '?'
+func winAnsi(r rune) byte {
+	if c, ok := winAnsiExtra[r]; ok {
+		return c
+	}
+	switch {
+	case r < 0x80 || (r >= 0xa0 && r <= 0xff):
+		return byte(r)
+	case r >= 0x100 && r < 0x100+rune(len(latinExtendedA)):
+		return latinExtendedA[r-0x100]
+	}
+	return '?'
+}
+
+// Split text into lines of at most width characters at word boundaries
+func wrapText(s string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		for len([]rune(word)) > width {
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			r := []rune(word)
+			lines = append(lines, string(r[:width]))
+			word = string(r[width:])
+		}
+		switch {
+		case line == "":
+			line = word
+		case len([]rune(line))+1+len([]rune(word)) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,151 @@
+package viesapi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWriteCertificate(t *testing.T) {
+	data := &VIESData{
+		UID:           "test-uid",
+		CountryCode:   "PL",
+		VATNumber:     "7272445205",
+		Valid:         true,
+		TraderName:    "Spółka (Test) Sp. z o.o.",
+		TraderAddress: "ul. Długa 1\n00-001 Łódź",
+		ID:            "c0ffee",
+		Date:          "2024-01-15",
+		Source:        "http://ec.europa.eu",
+	}
+
+	var b bytes.Buffer
+	if err := data.WriteCertificate(&b); err != nil {
+		t.Fatal(err)
+	}
+	pdf := b.String()
+
+	if !strings.HasPrefix(pdf, "%PDF-1.4\n") || !strings.HasSuffix(pdf, "%%EOF\n") {
+		t.Fatalf("missing PDF header or trailer")
+	}
+	for _, want := range []string{
+		"(Sp\xf3lka \\(Test\\) Sp. z o.o.)",
+		"(ul. Dluga 1 00-001 L\xf3dz)",
+		"(PL7272445205)",
+		"(VALID)",
+		"(2024-01-15)",
+		"(Current)",
+		"(c0ffee)",
+		"(test-uid)",
+		"(http://ec.europa.eu)",
+	} {
+		if !strings.Contains(pdf, want) {
+			t.Errorf("certificate does not contain %q", want)
+		}
+	}
+
+	// every cross-reference entry must point at its object
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindStringSubmatch(pdf)
+	if m == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(m[1])
+	if !strings.HasPrefix(pdf[xref:], "xref\n0 7\n") {
+		t.Fatalf("startxref %d does not point at xref table", xref)
+	}
+	entries := strings.Split(pdf[xref:], "\n")[3:9]
+	for i, entry := range entries {
+		off, _ := strconv.Atoi(entry[:10])
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !strings.HasPrefix(pdf[off:], want) {
+			t.Errorf("xref entry %d = %d, does not point at object", i+1, off)
+		}
+	}
+
+	// stream length must match its content
+	m = regexp.MustCompile(`(?s)/Length (\d+) >>\nstream\n(.*)endstream`).FindStringSubmatch(pdf)
+	if n, _ := strconv.Atoi(m[1]); n != len(m[2]) {
+		t.Errorf("stream length = %d, want %d", n, len(m[2]))
+	}
+}
+
+func TestWriteCertificateDegraded(t *testing.T) {
+	tests := []struct {
+		data VIESData
+		want string
+	}{
+		{VIESData{Valid: true, Stale: true}, "(Stale - cached result past its validity)"},
+		{VIESData{Valid: true, Stale: true, Degraded: true}, "(Degraded - last known result, the lookup failed)"},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		tt.data.WriteCertificate(&b)
+		if !strings.Contains(b.String(), tt.want) {
+			t.Errorf("certificate of %+v does not contain %q", tt.data, tt.want)
+		}
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestWriteCertificateError(t *testing.T) {
+	err := (&VIESData{}).WriteCertificate(failingWriter{})
+	var ve *ViesError
+	if !errors.As(err, &ve) || ve.Code != DOCUMENT_PDF {
+		t.Errorf("error = %v, want DOCUMENT_PDF", err)
+	}
+}
+
+func TestWinAnsi(t *testing.T) {
+	if len(latinExtendedA) != 0x80 {
+		t.Fatalf("latinExtendedA has %d letters, want 128", len(latinExtendedA))
+	}
+
+	tests := []struct {
+		r    rune
+		want byte
+	}{
+		{'A', 'A'},
+		{'é', 0xe9},
+		{'€', 0x80},
+		{'Š', 0x8a},
+		{'ł', 'l'},
+		{'Ż', 'Z'},
+		{'ř', 'r'},
+		{'ő', 'o'},
+		{'Ж', '?'},
+	}
+
+	for _, tt := range tests {
+		if got := winAnsi(tt.r); got != tt.want {
+			t.Errorf("winAnsi(%q) = %#x, want %#x", tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  []string
+	}{
+		{"", 10, []string{""}},
+		{"short", 10, []string{"short"}},
+		{"one two three four", 9, []string{"one two", "three", "four"}},
+		{"abcdefghijkl", 5, []string{"abcde", "fghij", "kl"}},
+	}
+
+	for _, tt := range tests {
+		got := wrapText(tt.s, tt.width)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("wrapText(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.want)
+		}
+	}
+}
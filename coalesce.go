@@ -0,0 +1,83 @@
+package viesapi
+
+import (
+	"context"
+	"sync"
+)
+
+// Deduplicates concurrent lookups of the same number
+type flight struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// In-flight lookup shared by all waiters for the same number
+type flightCall struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+	data    *VIESData
+	err     *ViesError
+}
+
+// Run fn for specified key unless the same lookup is already in flight and
+// return an independent copy of its result, reporting whether the lookup was
+// started by another caller. The shared call is cancelled only when every
+// waiter has given up.
+func (f *flight) do(ctx context.Context, key string, fn func(context.Context) (*VIESData, *ViesError)) (*VIESData, *ViesError, bool) {
+
+	f.mu.Lock()
+	if f.calls == nil {
+		f.calls = make(map[string]*flightCall)
+	}
+	fc, ok := f.calls[key]
+	if ok {
+		fc.waiters++
+	} else {
+		// keep values such as trace hooks of the first caller but not its cancellation
+		shared, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		fc = &flightCall{done: make(chan struct{}), cancel: cancel, waiters: 1}
+		f.calls[key] = fc
+
+		go func() {
+			fc.data, fc.err = fn(shared)
+			cancel()
+
+			f.mu.Lock()
+			if f.calls[key] == fc {
+				delete(f.calls, key)
+			}
+			f.mu.Unlock()
+			close(fc.done)
+		}()
+	}
+	f.mu.Unlock()
+
+	select {
+	case <-fc.done:
+		data, e := fc.result()
+		return data, e, ok
+	case <-ctx.Done():
+		f.mu.Lock()
+		fc.waiters--
+		if fc.waiters == 0 {
+			// nobody is interested anymore, later lookups must start afresh
+			fc.cancel()
+			if f.calls[key] == fc {
+				delete(f.calls, key)
+			}
+		}
+		f.mu.Unlock()
+		return nil, &ViesError{Code: CLI_CONNECT, Description: ctx.Err().Error(), err: ctx.Err()}, ok
+	}
+}
+
+// Get copy of the shared result
+func (fc *flightCall) result() (*VIESData, *ViesError) {
+	if fc.err != nil {
+		e := *fc.err
+		return nil, &e
+	}
+	data := *fc.data
+	return &data, nil
+}
@@ -0,0 +1,124 @@
+package viesapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const coalesceXML = `<result><vies><uid>test-uid</uid><countryCode>PL</countryCode><valid>true</valid><vatNumber>7272445205</vatNumber><date>2024-01-15</date></vies><error><code>0</code></error></result>`
+
+// Wait until n waiters have joined the lookup of key in flight
+func waitWaiters(t *testing.T, f *flight, key string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		f.mu.Lock()
+		fc := f.calls[key]
+		joined := fc != nil && fc.waiters == n
+		f.mu.Unlock()
+		if joined {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d waiters did not join the lookup of %s", n, key)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCoalescing(t *testing.T) {
+	var hits int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-release
+		w.Write([]byte(coalesceXML))
+	}))
+	defer server.Close()
+
+	c := NewVIESClient("test_id", "test_key")
+	c.SetUrl(server.URL)
+
+	results := make([]*VIESData, 10)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			data, err := c.GetVIESData("PL7272445205")
+			if err != nil {
+				t.Errorf("GetVIESData returned error: %v", err)
+			}
+			results[i] = data
+		}(i)
+	}
+
+	waitWaiters(t, &c.flight, "PL7272445205", len(results))
+	close(release)
+	wg.Wait()
+
+	if hits != 1 {
+		t.Errorf("hits = %d, want 1", hits)
+	}
+	results[0].UID = "changed"
+	for _, data := range results[1:] {
+		if data == results[0] || data.UID != "test-uid" {
+			t.Fatal("waiters share the same VIESData instance")
+		}
+	}
+}
+
+func TestCoalescingCancel(t *testing.T) {
+	canceled := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+			w.Write([]byte(coalesceXML))
+		case <-r.Context().Done():
+			close(canceled)
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	c := NewVIESClient("test_id", "test_key")
+	c.SetUrl(server.URL)
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+
+	errs := make(chan *ViesError, 2)
+	for _, ctx := range []context.Context{ctx1, ctx2} {
+		go func(ctx context.Context) {
+			_, err := c.GetVIESDataContext(ctx, "PL7272445205")
+			errs <- err
+		}(ctx)
+	}
+	waitWaiters(t, &c.flight, "PL7272445205", 2)
+
+	// one waiter leaving must not abandon the shared call
+	cancel1()
+	if err := <-errs; err == nil || err.Code != CLI_CONNECT {
+		t.Errorf("error = %v, want CLI_CONNECT", err)
+	}
+	select {
+	case <-canceled:
+		t.Fatal("shared call cancelled while a waiter remains")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// the last waiter leaving cancels the upstream request
+	cancel2()
+	<-errs
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("shared call not cancelled after all waiters left")
+	}
+}
@@ -0,0 +1,226 @@
+package viesapi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Credentials are not available from the provider
+var ErrNoCredentials = errors.New("viesapi: credentials not available")
+
+const redacted = "[REDACTED]"
+
+// API key identifier and secret key of the account
+type Credentials struct {
+	ID  string
+	Key string
+}
+
+// Return credentials with the key redacted
+func (c Credentials) String() string {
+	return fmt.Sprintf("{ID:%s Key:%s}", c.ID, redacted)
+}
+
+// Return credentials with the key redacted
+func (c Credentials) GoString() string {
+	return fmt.Sprintf("viesapi.Credentials{ID:%q, Key:%q}", c.ID, redacted)
+}
+
+// Format credentials with the key redacted whatever the verb and flags
+func (c Credentials) Format(f fmt.State, verb rune) {
+	formatRedacted(f, verb, c.String(), c.GoString())
+}
+
+// CredentialsProvider supplies credentials for every call, so that keys can
+// rotate without restarting the client
+type CredentialsProvider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+type staticCredentials Credentials
+
+// Create provider of fixed credentials
+func StaticCredentials(id, key string) CredentialsProvider {
+	return staticCredentials{ID: id, Key: key}
+}
+
+// Return provider description with the key redacted
+func (s staticCredentials) String() string {
+	return fmt.Sprintf("StaticCredentials{ID:%s Key:%s}", s.ID, redacted)
+}
+
+// Return provider description with the key redacted
+func (s staticCredentials) GoString() string {
+	return fmt.Sprintf("viesapi.StaticCredentials(%q, %q)", s.ID, redacted)
+}
+
+// Format provider with the key redacted whatever the verb and flags
+func (s staticCredentials) Format(f fmt.State, verb rune) {
+	formatRedacted(f, verb, s.String(), s.GoString())
+}
+
+func (s staticCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	if s.ID == "" || s.Key == "" {
+		return Credentials{}, ErrNoCredentials
+	}
+	return Credentials(s), nil
+}
+
+type envCredentials struct {
+	id, key string
+}
+
+// Create provider reading credentials from specified environment variables on every call
+func EnvCredentials(idVar, keyVar string) CredentialsProvider {
+	return envCredentials{id: idVar, key: keyVar}
+}
+
+func (e envCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	c := Credentials{ID: os.Getenv(e.id), Key: os.Getenv(e.key)}
+	if c.ID == "" || c.Key == "" {
+		return Credentials{}, fmt.Errorf("%w: %s or %s not set", ErrNoCredentials, e.id, e.key)
+	}
+	return c, nil
+}
+
+type fileCredentials struct {
+	path  string
+	mu    sync.Mutex
+	mtime time.Time
+	size  int64
+	creds Credentials
+}
+
+// Create provider reading credentials from file with the identifier on the
+// first line and the key on the second one, re-read whenever the file changes
+func FileCredentials(path string) CredentialsProvider {
+	return &fileCredentials{path: path}
+}
+
+// Return provider description without the cached credentials
+func (f *fileCredentials) String() string {
+	return fmt.Sprintf("FileCredentials{Path:%s}", f.path)
+}
+
+// Return provider description without the cached credentials
+func (f *fileCredentials) GoString() string {
+	return fmt.Sprintf("viesapi.FileCredentials(%q)", f.path)
+}
+
+// Format provider without the cached credentials whatever the verb and flags
+func (f *fileCredentials) Format(st fmt.State, verb rune) {
+	formatRedacted(st, verb, f.String(), f.GoString())
+}
+
+func (f *fileCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("%w: %v", ErrNoCredentials, err)
+	}
+	if fi.ModTime().Equal(f.mtime) && fi.Size() == f.size && f.creds != (Credentials{}) {
+		return f.creds, nil
+	}
+
+	b, err := os.ReadFile(f.path)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("%w: %v", ErrNoCredentials, err)
+	}
+	creds, err := parseCredentials(bytes.NewReader(b))
+	if err != nil {
+		return Credentials{}, fmt.Errorf("%w: %s: %v", ErrNoCredentials, f.path, err)
+	}
+
+	f.mtime = fi.ModTime()
+	f.size = fi.Size()
+	f.creds = creds
+	return creds, nil
+}
+
+// Parse identifier and key from their first two non-empty lines
+func parseCredentials(r io.Reader) (Credentials, error) {
+	var lines []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() && len(lines) < 2 {
+		if line := string(bytes.TrimSpace(sc.Bytes())); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return Credentials{}, err
+	}
+	if len(lines) < 2 {
+		return Credentials{}, errors.New("expected identifier and key lines")
+	}
+	return Credentials{ID: lines[0], Key: lines[1]}, nil
+}
+
+// Get current credentials from the provider and the previous ones if they
+// were rotated less than the grace period ago
+func (c *VIESClient) credentials(ctx context.Context) (Credentials, *Credentials, *ViesError) {
+	creds, err := c.provider.Credentials(ctx)
+	if err != nil {
+		return Credentials{}, nil, c.wrapError(CLI_CREDENTIALS, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock()
+	if creds != c.creds {
+		if c.creds != (Credentials{}) {
+			c.prev = c.creds
+			c.rotated = now
+		}
+		c.creds = creds
+	}
+	if c.prev == (Credentials{}) || now.Sub(c.rotated) >= c.grace {
+		return creds, nil, nil
+	}
+	prev := c.prev
+	return creds, &prev, nil
+}
+
+// Return description of the client with the key redacted
+func (c *VIESClient) String() string {
+	c.mu.Lock()
+	id := c.creds.ID
+	c.mu.Unlock()
+	return fmt.Sprintf("viesapi.VIESClient{URL:%s ID:%s Key:%s}", c.url, id, redacted)
+}
+
+// Return description of the client with the key redacted
+func (c *VIESClient) GoString() string {
+	c.mu.Lock()
+	id := c.creds.ID
+	c.mu.Unlock()
+	return fmt.Sprintf("&viesapi.VIESClient{URL:%q, ID:%q, Key:%q}", c.url, id, redacted)
+}
+
+// Format the client with the key redacted whatever the verb and flags
+func (c *VIESClient) Format(f fmt.State, verb rune) {
+	formatRedacted(f, verb, c.String(), c.GoString())
+}
+
+// Write redacted description for any verb, so that no verb falls back to
+// printing the underlying fields
+func formatRedacted(f fmt.State, verb rune, s, gs string) {
+	switch {
+	case verb == 'v' && f.Flag('#'):
+		io.WriteString(f, gs)
+	case verb == 'q':
+		io.WriteString(f, strconv.Quote(s))
+	default:
+		io.WriteString(f, s)
+	}
+}
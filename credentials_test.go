@@ -0,0 +1,179 @@
+package viesapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnvCredentials(t *testing.T) {
+	t.Setenv("TEST_VIESAPI_ID", "env_id")
+	t.Setenv("TEST_VIESAPI_KEY", "env_key")
+
+	p := EnvCredentials("TEST_VIESAPI_ID", "TEST_VIESAPI_KEY")
+	creds, err := p.Credentials(context.Background())
+	if err != nil || creds != (Credentials{ID: "env_id", Key: "env_key"}) {
+		t.Errorf("Credentials = %v, %v; want env_id", creds, err)
+	}
+
+	// rotation is picked up on the next call
+	t.Setenv("TEST_VIESAPI_KEY", "")
+	if _, err := p.Credentials(context.Background()); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("Credentials with unset key = %v, want ErrNoCredentials", err)
+	}
+}
+
+func TestFileCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	p := FileCredentials(path)
+
+	if _, err := p.Credentials(context.Background()); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("Credentials of missing file = %v, want ErrNoCredentials", err)
+	}
+
+	os.WriteFile(path, []byte("file_id\nold_key\n"), 0o600)
+	creds, err := p.Credentials(context.Background())
+	if err != nil || creds != (Credentials{ID: "file_id", Key: "old_key"}) {
+		t.Errorf("Credentials = %v, %v; want old_key", creds, err)
+	}
+
+	os.WriteFile(path, []byte("\nfile_id\n  new_key_longer  \n"), 0o600)
+	os.Chtimes(path, time.Now(), time.Now().Add(time.Second))
+	creds, _ = p.Credentials(context.Background())
+	if creds.Key != "new_key_longer" {
+		t.Errorf("Credentials after rotation = %#v, want new_key_longer", creds)
+	}
+
+	os.WriteFile(path, []byte("file_id\n"), 0o600)
+	if _, err := p.Credentials(context.Background()); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("Credentials of incomplete file = %v, want ErrNoCredentials", err)
+	}
+}
+
+// Rotating provider returning the next credentials on every call
+type rotatingCredentials struct {
+	keys []string
+}
+
+func (r *rotatingCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	key := r.keys[0]
+	if len(r.keys) > 1 {
+		r.keys = r.keys[1:]
+	}
+	return Credentials{ID: "test_id", Key: key}, nil
+}
+
+func TestRotationGrace(t *testing.T) {
+	// the service accepts only the old key
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := "old_key"
+		if _, err := NewSigner("test_id", "old_key", nil, nil).Verify("GET", "http://"+r.Host+r.URL.Path, r.Header.Get("Authorization")); err != nil {
+			key = "new_key"
+			w.Write([]byte(`<result><error><code>55</code><description>Invalid MAC</description></error></result>`))
+		} else {
+			w.Write([]byte(`<result><account><uid>test-uid</uid></account></result>`))
+		}
+		keys = append(keys, key)
+	}))
+	defer server.Close()
+
+	// the provider rotates the key on the second call, the third call comes after the delay
+	tests := []struct {
+		name  string
+		grace time.Duration
+		delay time.Duration
+		codes [2]int
+		keys  string
+	}{
+		{"within grace", time.Hour, time.Minute, [2]int{0, 0}, "old_key,new_key,old_key,new_key,old_key"},
+		{"after grace", time.Hour, 2 * time.Hour, [2]int{0, AUTH_MAC}, "old_key,new_key,old_key,new_key"},
+		{"no grace", 0, 0, [2]int{AUTH_MAC, AUTH_MAC}, "old_key,new_key,new_key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			keys = nil
+			c := NewVIESClient("", "",
+				WithCredentials(&rotatingCredentials{keys: []string{"old_key", "new_key"}}),
+				WithRotationGrace(tt.grace),
+				WithClock(func() time.Time { return now }))
+			c.SetUrl(server.URL)
+
+			if _, e := c.GetAccountStatus(); e != nil {
+				t.Fatalf("GetAccountStatus with old key returned error: %v", e)
+			}
+
+			for i, want := range tt.codes {
+				if i == 1 {
+					now = now.Add(tt.delay)
+				}
+				_, e := c.GetAccountStatus()
+				code := 0
+				if e != nil {
+					code = e.Code
+				}
+				if code != want {
+					t.Errorf("call %d error = %v, want code %d", i+2, e, want)
+				}
+			}
+			if got := strings.Join(keys, ","); got != tt.keys {
+				t.Errorf("keys = %s, want %s", got, tt.keys)
+			}
+		})
+	}
+}
+
+func TestCredentialsProviderError(t *testing.T) {
+	c := NewVIESClient("", "", WithCredentials(EnvCredentials("TEST_VIESAPI_UNSET_ID", "TEST_VIESAPI_UNSET_KEY")))
+	if c.url != production_url {
+		t.Errorf("url = %s, want %s", c.url, production_url)
+	}
+
+	_, e := c.GetAccountStatus()
+	if e == nil || e.Code != CLI_CREDENTIALS || !errors.Is(e, ErrNoCredentials) {
+		t.Errorf("error = %v, want CLI_CREDENTIALS", e)
+	}
+}
+
+func TestClientRedaction(t *testing.T) {
+	c := NewVIESClient("my_id", "secret_key")
+
+	for _, format := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x"} {
+		out := fmt.Sprintf(format, c)
+		if strings.Contains(out, "secret_key") || !strings.Contains(out, redacted) || !strings.Contains(out, "my_id") {
+			t.Errorf("Sprintf(%s) = %s, want redacted key", format, out)
+		}
+	}
+	for _, format := range []string{"%v", "%+v", "%#v", "%d"} {
+		if out := fmt.Sprintf(format, c.creds); strings.Contains(out, "secret_key") {
+			t.Errorf("Sprintf(%s) of credentials = %s, want redacted key", format, out)
+		}
+	}
+}
+
+func TestProviderRedaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	os.WriteFile(path, []byte("file_id\nsecret_key\n"), 0o600)
+	file := FileCredentials(path)
+	if _, err := file.Credentials(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	providers := []CredentialsProvider{StaticCredentials("my_id", "secret_key"), file}
+	for _, p := range providers {
+		for _, format := range []string{"%v", "%+v", "%#v", "%s", "%q", "%d"} {
+			if out := fmt.Sprintf(format, p); strings.Contains(out, "secret_key") {
+				t.Errorf("Sprintf(%s) of %T = %s, want redacted key", format, p, out)
+			}
+		}
+	}
+}
@@ -0,0 +1,206 @@
+// Package ecvies implements viesapi.Verifier on top of the checkVatService
+// SOAP service of the European Commission VIES system.
+package ecvies
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/glaydus/viesapi"
+)
+
+// Address of the EC VIES checkVatService endpoint
+const DefaultURL = "https://ec.europa.eu/taxation_customs/vies/services/checkVatService"
+
+// Source reported in VIES data returned by the client
+const Source = "http://ec.europa.eu"
+
+// Max size of the service response
+const maxResponseSize = 1 << 20
+
+// checkVat request envelope, element names carry the prefixes declared on the root
+type request struct {
+	XMLName xml.Name `xml:"soapenv:Envelope"`
+	SOAPEnv string   `xml:"xmlns:soapenv,attr"`
+	URN     string   `xml:"xmlns:urn,attr"`
+	Header  struct{} `xml:"soapenv:Header"`
+	Body    struct {
+		CountryCode string `xml:"urn:checkVat>urn:countryCode"`
+		VATNumber   string `xml:"urn:checkVat>urn:vatNumber"`
+	} `xml:"soapenv:Body"`
+}
+
+// Build checkVat request for specified normalized number, values are escaped by the encoder
+func newRequest(number string) ([]byte, error) {
+	req := request{
+		SOAPEnv: "http://schemas.xmlsoap.org/soap/envelope/",
+		URN:     "urn:ec.europa.eu:taxud:vies:services:checkVat:types",
+	}
+	req.Body.CountryCode = number[:2]
+	req.Body.VATNumber = number[2:]
+
+	b, err := xml.Marshal(&req)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+type envelope struct {
+	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
+	Body    struct {
+		Response *checkVatResponse `xml:"urn:ec.europa.eu:taxud:vies:services:checkVat:types checkVatResponse"`
+		Fault    *fault            `xml:"http://schemas.xmlsoap.org/soap/envelope/ Fault"`
+	} `xml:"http://schemas.xmlsoap.org/soap/envelope/ Body"`
+}
+
+type checkVatResponse struct {
+	CountryCode string `xml:"countryCode"`
+	VATNumber   string `xml:"vatNumber"`
+	RequestDate string `xml:"requestDate"`
+	Valid       *bool  `xml:"valid"`
+	Name        string `xml:"name"`
+	Address     string `xml:"address"`
+}
+
+type fault struct {
+	Code   string `xml:"faultcode"`
+	String string `xml:"faultstring"`
+}
+
+// VIES API error codes of checkVatService fault strings
+var faults = map[string]int{
+	"INVALID_INPUT":                  viesapi.EUVAT_BAD,
+	"MS_UNAVAILABLE":                 viesapi.VIES_SYNC,
+	"TIMEOUT":                        viesapi.VIES_SYNC,
+	"MS_MAX_CONCURRENT_REQ":          viesapi.VIES_SYNC,
+	"MS_MAX_CONCURRENT_REQ_TIME":     viesapi.VIES_SYNC,
+	"SERVICE_UNAVAILABLE":            viesapi.MAINTENANCE,
+	"SERVER_BUSY":                    viesapi.MAINTENANCE,
+	"GLOBAL_MAX_CONCURRENT_REQ":      viesapi.MAINTENANCE,
+	"GLOBAL_MAX_CONCURRENT_REQ_TIME": viesapi.MAINTENANCE,
+	"INVALID_REQUESTER_INFO":         viesapi.CLI_INPUT,
+	"VAT_BLOCKED":                    viesapi.ACCESS_DENIED,
+	"IP_BLOCKED":                     viesapi.ACCESS_DENIED,
+}
+
+// Option configures Client
+type Option func(*Client)
+
+// Use specified HTTP client for requests to the service
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.client = client
+	}
+}
+
+// Use service at specified URL instead of DefaultURL
+func WithURL(url string) Option {
+	return func(c *Client) {
+		c.url = url
+	}
+}
+
+// Client checks EU VAT numbers directly in the EC VIES system
+type Client struct {
+	url    string
+	client *http.Client
+}
+
+var _ viesapi.Verifier = (*Client)(nil)
+
+// Create new Client
+func New(opts ...Option) *Client {
+	c := &Client{
+		url:    DefaultURL,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Get VIES data for specified number from EC VIES system
+// GetVIESData returns VIES data or nil in case of error
+func (c *Client) GetVIESData(euvat string) (*viesapi.VIESData, *viesapi.ViesError) {
+	return c.GetVIESDataContext(context.Background(), euvat)
+}
+
+// Get VIES data for specified number from EC VIES system using specified context
+// GetVIESDataContext returns VIES data or nil in case of error
+func (c *Client) GetVIESDataContext(ctx context.Context, euvat string) (*viesapi.VIESData, *viesapi.ViesError) {
+	number, ok := viesapi.NormalizeEUVAT(euvat)
+	if !ok {
+		return nil, newError(viesapi.CLI_EUVAT, "EU VAT ID is invalid")
+	}
+
+	body, err := newRequest(number)
+	if err != nil {
+		return nil, newError(viesapi.CLI_EXCEPTION, err.Error())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, newError(viesapi.CLI_CONNECT, err.Error())
+	}
+	req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+	req.Header.Set("SOAPAction", "")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, newError(viesapi.CLI_CONNECT, err.Error())
+	}
+	defer res.Body.Close()
+
+	// faults come with status 500, so the body is parsed whatever the status
+	var env envelope
+	if err := xml.NewDecoder(io.LimitReader(res.Body, maxResponseSize)).Decode(&env); err != nil {
+		if res.StatusCode >= 500 {
+			return nil, newError(viesapi.MAINTENANCE, "EC VIES service is temporarily unavailable")
+		}
+		return nil, newError(viesapi.CLI_RESPONSE, "EC VIES service response has invalid format")
+	}
+
+	if f := env.Body.Fault; f != nil {
+		code, ok := faults[strings.TrimSpace(f.String)]
+		if !ok {
+			code = viesapi.CLI_RESPONSE
+		}
+		return nil, newError(code, f.String)
+	}
+
+	r := env.Body.Response
+	if r == nil || r.Valid == nil || r.CountryCode == "" || r.VATNumber == "" || r.RequestDate == "" {
+		return nil, newError(viesapi.CLI_RESPONSE, "EC VIES service response is missing required element")
+	}
+	return r.data(), nil
+}
+
+// Map checkVat response onto VIES data
+func (r *checkVatResponse) data() *viesapi.VIESData {
+	date := r.RequestDate
+	if len(date) > 10 {
+		// drop time zone of the xsd:date value
+		date = date[:10]
+	}
+	return &viesapi.VIESData{
+		CountryCode:   r.CountryCode,
+		VATNumber:     r.VATNumber,
+		Valid:         *r.Valid,
+		TraderName:    strings.TrimSpace(r.Name),
+		TraderAddress: strings.TrimSpace(r.Address),
+		Date:          date,
+		Source:        Source,
+	}
+}
+
+// Create error with specified code and description
+func newError(code int, msg string) *viesapi.ViesError {
+	return &viesapi.ViesError{Code: code, Description: msg}
+}
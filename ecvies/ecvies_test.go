@@ -0,0 +1,150 @@
+package ecvies
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/glaydus/viesapi"
+)
+
+const responseXML = `<env:Envelope xmlns:env="http://schemas.xmlsoap.org/soap/envelope/"><env:Header/><env:Body><ns2:checkVatResponse xmlns:ns2="urn:ec.europa.eu:taxud:vies:services:checkVat:types"><ns2:countryCode>%s</ns2:countryCode><ns2:vatNumber>%s</ns2:vatNumber><ns2:requestDate>2024-01-15+01:00</ns2:requestDate><ns2:valid>%s</ns2:valid><ns2:name>%s</ns2:name><ns2:address>%s</ns2:address></ns2:checkVatResponse></env:Body></env:Envelope>`
+
+const faultXML = `<env:Envelope xmlns:env="http://schemas.xmlsoap.org/soap/envelope/"><env:Body><env:Fault><faultcode>env:Server</faultcode><faultstring>%s</faultstring></env:Fault></env:Body></env:Envelope>`
+
+// checkVat request as received by the stand-in server
+type checkVat struct {
+	CountryCode string `xml:"Body>checkVat>countryCode"`
+	VATNumber   string `xml:"Body>checkVat>vatNumber"`
+}
+
+// Start SOAP stand-in of checkVatService
+func newStandIn(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "text/xml; charset=utf-8" {
+			t.Errorf("request %s %s, want SOAP POST", r.Method, r.Header.Get("Content-Type"))
+		}
+		var req checkVat
+		if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid SOAP request: %v", err)
+		}
+
+		w.Header().Set("Content-Type", "text/xml;charset=UTF-8")
+		switch req.CountryCode + req.VATNumber {
+		case "PL7272445205":
+			w.Write([]byte(fmt.Sprintf(responseXML, req.CountryCode, req.VATNumber, "true", "NETCAT SP. Z O.O.", "\nUL. PIOTRKOWSKA 1\n90-001 ŁÓDŹ\n")))
+		case "DE123456789":
+			w.Write([]byte(fmt.Sprintf(responseXML, req.CountryCode, req.VATNumber, "false", "---", "---")))
+		case "IT12345678901":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(fmt.Sprintf(faultXML, "MS_UNAVAILABLE")))
+		case "FR12345678901":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(fmt.Sprintf(faultXML, "GLOBAL_MAX_CONCURRENT_REQ")))
+		case "EL123456789":
+			w.Write([]byte(`<env:Envelope xmlns:env="http://schemas.xmlsoap.org/soap/envelope/"><env:Body></env:Body></env:Envelope>`))
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("<html>Bad Gateway</html>"))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGetVIESData(t *testing.T) {
+	c := New(WithURL(newStandIn(t).URL))
+
+	data, e := c.GetVIESData("PL 727-244-52-05")
+	if e != nil {
+		t.Fatalf("GetVIESData returned error: %v", e)
+	}
+	want := viesapi.VIESData{
+		CountryCode:   "PL",
+		VATNumber:     "7272445205",
+		Valid:         true,
+		TraderName:    "NETCAT SP. Z O.O.",
+		TraderAddress: "UL. PIOTRKOWSKA 1\n90-001 ŁÓDŹ",
+		Date:          "2024-01-15",
+		Source:        Source,
+	}
+	if *data != want {
+		t.Errorf("VIES data = %+v, want %+v", *data, want)
+	}
+
+	data, e = c.GetVIESData("DE123456789")
+	if e != nil || data.Valid {
+		t.Errorf("GetVIESData(DE123456789) = %v, %v; want invalid", data, e)
+	}
+}
+
+func TestGetVIESDataErrors(t *testing.T) {
+	c := New(WithURL(newStandIn(t).URL))
+
+	tests := []struct {
+		number string
+		code   int
+	}{
+		{"PL7272445206", viesapi.CLI_EUVAT},
+		{"IT12345678901", viesapi.VIES_SYNC},
+		{"FR12345678901", viesapi.MAINTENANCE},
+		{"EL123456789", viesapi.CLI_RESPONSE},
+		{"ATU12345678", viesapi.MAINTENANCE},
+	}
+
+	for _, tt := range tests {
+		data, e := c.GetVIESData(tt.number)
+		if data != nil || e == nil || e.Code != tt.code {
+			t.Errorf("GetVIESData(%s) = %v, %v; want code %d", tt.number, data, e, tt.code)
+		}
+	}
+}
+
+func TestGetVIESDataConnect(t *testing.T) {
+	server := newStandIn(t)
+	c := New(WithURL(server.URL))
+	server.Close()
+
+	if _, e := c.GetVIESDataContext(context.Background(), "PL7272445205"); e == nil || e.Code != viesapi.CLI_CONNECT {
+		t.Errorf("error = %v, want CLI_CONNECT", e)
+	}
+}
+
+func TestRequest(t *testing.T) {
+	b, err := newRequest("PL7272445205")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := xml.Header + `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:urn="urn:ec.europa.eu:taxud:vies:services:checkVat:types"><soapenv:Header></soapenv:Header><soapenv:Body><urn:checkVat><urn:countryCode>PL</urn:countryCode><urn:vatNumber>7272445205</urn:vatNumber></urn:checkVat></soapenv:Body></soapenv:Envelope>`
+	if string(b) != want {
+		t.Errorf("request = %s, want %s", b, want)
+	}
+
+	// values are escaped even if they get past validation
+	b, _ = newRequest("DE1</urn:vatNumber><x>")
+	if strings.Contains(string(b), "<x>") {
+		t.Errorf("request = %s, want escaped number", b)
+	}
+}
+
+func TestGetVIESDataInjection(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+	}))
+	defer server.Close()
+	c := New(WithURL(server.URL))
+
+	for _, number := range []string{"DE123456789</urn:vatNumber><x>", "PL7272445205/../x"} {
+		if _, e := c.GetVIESData(number); e == nil || e.Code != viesapi.CLI_EUVAT {
+			t.Errorf("GetVIESData(%s) error = %v, want CLI_EUVAT", number, e)
+		}
+	}
+	if hits != 0 {
+		t.Errorf("service hits = %d, want 0", hits)
+	}
+}
@@ -0,0 +1,167 @@
+package viesapi
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Output format of VIES data and account status encoders
+type Format int
+
+const (
+	// Compact JSON
+	FormatJSON Format = iota
+	// JSON indented with two spaces
+	FormatJSONIndent
+	// XML using the element names of the service
+	FormatXML
+	// CSV header followed by single row
+	FormatCSV
+	// Aligned human-readable table
+	FormatText
+)
+
+var formats = map[string]Format{
+	"json":        FormatJSON,
+	"json-indent": FormatJSONIndent,
+	"xml":         FormatXML,
+	"csv":         FormatCSV,
+	"text":        FormatText,
+}
+
+// Get format by its name: json, json-indent, xml, csv or text
+func ParseFormat(name string) (Format, error) {
+	f, ok := formats[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("viesapi: unknown format %q", name)
+	}
+	return f, nil
+}
+
+// Return format name
+func (f Format) String() string {
+	for name, v := range formats {
+		if v == f {
+			return name
+		}
+	}
+	return "Format(" + strconv.Itoa(int(f)) + ")"
+}
+
+// Single encoded field with its CSV column name and human-readable label
+type field struct {
+	name  string
+	label string
+	value string
+}
+
+// Write VIES data to w in specified format
+func (v *VIESData) Encode(w io.Writer, format Format) error {
+	return encode(w, format, "vies", v, v.fields())
+}
+
+// Write account status to w in specified format
+func (a *AccountStatus) Encode(w io.Writer, format Format) error {
+	return encode(w, format, "account", a, a.fields())
+}
+
+// Get fields of VIES data in stable order
+func (v *VIESData) fields() []field {
+	return []field{
+		{"uid", "UID", v.UID},
+		{"country_code", "Country code", v.CountryCode},
+		{"vat_number", "VAT number", v.VATNumber},
+		{"valid", "Valid", strconv.FormatBool(v.Valid)},
+		{"trader_name", "Trader name", v.TraderName},
+		{"trader_company_type", "Trader company type", v.TraderCompanyType},
+		{"trader_address", "Trader address", v.TraderAddress},
+		{"id", "ID", v.ID},
+		{"date", "Date", v.Date},
+		{"source", "Source", v.Source},
+		{"stale", "Stale", strconv.FormatBool(v.Stale)},
+		{"degraded", "Degraded", strconv.FormatBool(v.Degraded)},
+	}
+}
+
+// Get fields of account status in stable order
+func (a *AccountStatus) fields() []field {
+	validTo := ""
+	if a.ValidTo != nil {
+		validTo = a.ValidTo.Format(time.RFC3339)
+	}
+	price := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	return []field{
+		{"uid", "UID", a.UID},
+		{"type", "Type", a.Type},
+		{"valid_to", "Valid to", validTo},
+		{"billing_plan_name", "Billing plan", a.BillingPlanName},
+		{"subscription_price", "Subscription price", price(a.SubscriptionPrice)},
+		{"item_price", "Item price", price(a.ItemPrice)},
+		{"item_price_status", "Item price (status)", price(a.ItemPriceStatus)},
+		{"limit", "Limit", strconv.Itoa(a.Limit)},
+		{"request_delay", "Request delay", strconv.Itoa(a.RequestDelay)},
+		{"domain_limit", "Domain limit", strconv.Itoa(a.DomainLimit)},
+		{"over_plan_allowed", "Over plan allowed", strconv.FormatBool(a.OverPlanAllowed)},
+		{"excel_add_in", "Excel add-in", strconv.FormatBool(a.ExcelAddIn)},
+		{"app", "App", strconv.FormatBool(a.App)},
+		{"cli", "CLI", strconv.FormatBool(a.CLI)},
+		{"stats", "Stats", strconv.FormatBool(a.Stats)},
+		{"monitor", "Monitor", strconv.FormatBool(a.Monitor)},
+		{"func_get_vies_data", "Get VIES data", strconv.FormatBool(a.FuncGetVIESData)},
+		{"vies_data_count", "VIES data count", strconv.Itoa(a.VIESDataCount)},
+		{"total_count", "Total count", strconv.Itoa(a.TotalCount)},
+	}
+}
+
+// Write value in specified format, using root element name for XML and fields for CSV and text
+func encode(w io.Writer, format Format, root string, v any, fields []field) error {
+	switch format {
+	case FormatJSON:
+		return json.NewEncoder(w).Encode(v)
+
+	case FormatJSONIndent:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+
+	case FormatXML:
+		enc := xml.NewEncoder(w)
+		enc.Indent("", "  ")
+		if err := enc.EncodeElement(v, xml.StartElement{Name: xml.Name{Local: root}}); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, "\n")
+		return err
+
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		header := make([]string, len(fields))
+		row := make([]string, len(fields))
+		for i, f := range fields {
+			header[i] = f.name
+			row[i] = f.value
+		}
+		cw.Write(header)
+		cw.Write(row)
+		cw.Flush()
+		return cw.Error()
+
+	case FormatText:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, f := range fields {
+			fmt.Fprintf(tw, "%s:\t%s\n", f.label, strings.Join(strings.Fields(f.value), " "))
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("viesapi: unknown format %v", format)
+}
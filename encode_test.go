@@ -0,0 +1,154 @@
+package viesapi
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func encodeFixtures() (*VIESData, *AccountStatus) {
+	validTo := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	data := &VIESData{
+		UID:               "test-uid",
+		CountryCode:       "PL",
+		VATNumber:         "7272445205",
+		Valid:             true,
+		TraderName:        `"Test" & Sons, Sp. z o.o.`,
+		TraderCompanyType: "---",
+		TraderAddress:     "ul. Długa 1\n00-001 Łódź",
+		ID:                "c0ffee",
+		Date:              "2024-01-15",
+		Source:            "http://ec.europa.eu",
+	}
+	status := &AccountStatus{
+		UID:               "account-uid",
+		Type:              "Business",
+		ValidTo:           &validTo,
+		BillingPlanName:   "Pro",
+		SubscriptionPrice: 49.5,
+		ItemPrice:         0.1,
+		Limit:             1000,
+		RequestDelay:      1,
+		DomainLimit:       5,
+		OverPlanAllowed:   true,
+		App:               true,
+		FuncGetVIESData:   true,
+		VIESDataCount:     42,
+		TotalCount:        50,
+	}
+	return data, status
+}
+
+func TestEncodeGolden(t *testing.T) {
+	data, status := encodeFixtures()
+	encoders := []struct {
+		name   string
+		encode func(*bytes.Buffer, Format) error
+	}{
+		{"vies", func(b *bytes.Buffer, f Format) error { return data.Encode(b, f) }},
+		{"account", func(b *bytes.Buffer, f Format) error { return status.Encode(b, f) }},
+	}
+	exts := map[Format]string{
+		FormatJSON:       "json",
+		FormatJSONIndent: "indent.json",
+		FormatXML:        "xml",
+		FormatCSV:        "csv",
+		FormatText:       "txt",
+	}
+
+	for _, enc := range encoders {
+		for format, ext := range exts {
+			name := enc.name + "." + ext
+			t.Run(name, func(t *testing.T) {
+				var b bytes.Buffer
+				if err := enc.encode(&b, format); err != nil {
+					t.Fatal(err)
+				}
+
+				golden := filepath.Join("testdata", "encode", name)
+				if *update {
+					if err := os.WriteFile(golden, b.Bytes(), 0o644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(b.Bytes(), want) {
+					t.Errorf("output differs from %s:\n%s", golden, b.String())
+				}
+			})
+		}
+	}
+}
+
+func TestEncodeCSVHeader(t *testing.T) {
+	data, _ := encodeFixtures()
+	degraded := *data
+	degraded.Stale, degraded.Degraded = true, true
+
+	// results of one batch share the header whatever their flags
+	var fresh, stale bytes.Buffer
+	data.Encode(&fresh, FormatCSV)
+	degraded.Encode(&stale, FormatCSV)
+	header := func(b []byte) string {
+		return string(b[:bytes.IndexByte(b, '\n')])
+	}
+	if header(fresh.Bytes()) != header(stale.Bytes()) {
+		t.Errorf("headers differ:\n%s\n%s", header(fresh.Bytes()), header(stale.Bytes()))
+	}
+	if !bytes.HasSuffix(stale.Bytes(), []byte(",true,true\n")) {
+		t.Errorf("row = %q, want stale and degraded flags", stale.String())
+	}
+}
+
+func TestEncodeXMLRoundTrip(t *testing.T) {
+	data, status := encodeFixtures()
+
+	var b bytes.Buffer
+	data.Encode(&b, FormatXML)
+	var gotData VIESData
+	if err := xml.Unmarshal(b.Bytes(), &gotData); err != nil {
+		t.Fatal(err)
+	}
+	if gotData != *data {
+		t.Errorf("VIES data = %+v, want %+v", gotData, *data)
+	}
+
+	b.Reset()
+	status.Encode(&b, FormatXML)
+	var gotStatus AccountStatus
+	if err := xml.Unmarshal(b.Bytes(), &gotStatus); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&gotStatus, status) {
+		t.Errorf("account status = %+v, want %+v", gotStatus, *status)
+	}
+}
+
+func TestEncodeUnknownFormat(t *testing.T) {
+	data, _ := encodeFixtures()
+	if err := data.Encode(&bytes.Buffer{}, Format(99)); err == nil {
+		t.Error("Encode with unknown format returned nil error")
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, f := range []Format{FormatJSON, FormatJSONIndent, FormatXML, FormatCSV, FormatText} {
+		got, err := ParseFormat(f.String())
+		if err != nil || got != f {
+			t.Errorf("ParseFormat(%q) = %v, %v; want %v", f.String(), got, err, f)
+		}
+	}
+	if _, err := ParseFormat("yaml"); err == nil {
+		t.Error("ParseFormat(yaml) returned nil error")
+	}
+}
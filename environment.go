@@ -0,0 +1,135 @@
+package viesapi
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// Only one of the identifier and key was given
+	ErrPartialCredentials = errors.New("viesapi: both id and key are required")
+	// Client is, or the service reports it is, in test mode
+	ErrTestMode = errors.New("viesapi: test mode")
+)
+
+// Service environment used by the client
+type Environment int
+
+const (
+	// Production service with real data
+	Production Environment = iota
+	// Test service returning sample data for test credentials
+	Test
+	// Service at URL given by WithURL, such as a gateway
+	Custom
+)
+
+// Return environment name
+func (e Environment) String() string {
+	switch e {
+	case Production:
+		return "production"
+	case Test:
+		return "test"
+	case Custom:
+		return "custom"
+	}
+	return fmt.Sprintf("Environment(%d)", int(e))
+}
+
+// Use specified environment instead of choosing it from the credentials.
+// Of WithEnvironment and WithURL the last one given wins, so Production or
+// Test replaces a URL set before.
+func WithEnvironment(env Environment) Option {
+	return func(c *VIESClient) {
+		c.env = env
+		c.envSet = true
+	}
+}
+
+// Use service at specified URL, selecting the Custom environment. Of
+// WithEnvironment and WithURL the last one given wins.
+func WithURL(url string) Option {
+	return func(c *VIESClient) {
+		c.url = url
+		c.env = Custom
+		c.envSet = true
+	}
+}
+
+// Refuse to run in the Test environment or with the test credentials, calls
+// of such client fail with TEST_MODE
+func WithoutTestMode() Option {
+	return func(c *VIESClient) {
+		c.noTest = true
+	}
+}
+
+// Create new VIESClient instance for the Production environment unless an
+// option selects another one. Unlike NewVIESClient it never falls back to test
+// mode silently: partial or missing credentials outside the Test environment
+// are reported as error.
+func NewClient(id, key string, opts ...Option) (*VIESClient, error) {
+	c := newClient(opts)
+
+	switch {
+	case (id == "") != (key == ""):
+		return nil, ErrPartialCredentials
+	case c.env == Test && c.noTest:
+		return nil, fmt.Errorf("%w: refused by WithoutTestMode", ErrTestMode)
+	case c.env == Custom && c.url == "":
+		return nil, errors.New("viesapi: custom environment requires URL")
+	case c.format != ResponseXML && c.format != ResponseJSON:
+		return nil, fmt.Errorf("viesapi: unknown response format %v", c.format)
+	case id == "" && c.provider == nil && c.env != Test:
+		return nil, fmt.Errorf("%w: credentials required in %s environment", ErrNoCredentials, c.env)
+	}
+
+	c.setup(id, key)
+	return c, nil
+}
+
+// Check if the client runs in test mode, which means the Test environment
+// or the test credentials sent to any other URL
+func (c *VIESClient) IsTestMode() bool {
+	c.mu.Lock()
+	creds := c.creds
+	c.mu.Unlock()
+	return c.testMode(creds)
+}
+
+// Check if requests signed with specified credentials run in test mode
+func (c *VIESClient) testMode(creds Credentials) bool {
+	return c.env == Test || creds == Credentials{ID: test_id, Key: test_key}
+}
+
+// Apply environment and credentials, test credentials are used in the Test
+// environment if none were given
+func (c *VIESClient) setup(id, key string) {
+	switch c.env {
+	case Production:
+		c.url = production_url
+	case Test:
+		c.url = test_url
+	}
+
+	if c.provider == nil {
+		if c.env == Test && id == "" && key == "" {
+			id = test_id
+			key = test_key
+		}
+		c.creds = Credentials{ID: id, Key: key}
+		c.provider = StaticCredentials(id, key)
+	}
+}
+
+// Refuse the call if the client must not run in test mode, which includes
+// test credentials sent to a custom URL
+func (c *VIESClient) checkTestMode(creds Credentials) *ViesError {
+	if c.noTest && c.testMode(creds) {
+		e := c.newError(TEST_MODE, "Test mode refused by client configuration")
+		e.err = ErrTestMode
+		return e
+	}
+	return nil
+}
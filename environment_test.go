@@ -0,0 +1,144 @@
+package viesapi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		name     string
+		id       string
+		key      string
+		opts     []Option
+		err      error
+		wantURL  string
+		wantTest bool
+	}{
+		{"production", "my_id", "my_key", nil, nil, production_url, false},
+		{"partial credentials", "my_id", "", nil, ErrPartialCredentials, "", false},
+		{"missing credentials", "", "", nil, ErrNoCredentials, "", false},
+		{"provider", "", "", []Option{WithCredentials(StaticCredentials("p_id", "p_key"))}, nil, production_url, false},
+		{"test", "", "", []Option{WithEnvironment(Test)}, nil, test_url, true},
+		{"test refused", "", "", []Option{WithEnvironment(Test), WithoutTestMode()}, ErrTestMode, "", false},
+		{"custom", "my_id", "my_key", []Option{WithURL("https://gateway.local/api")}, nil, "https://gateway.local/api", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewClient(tt.id, tt.key, tt.opts...)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("NewClient error = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if c.url != tt.wantURL {
+				t.Errorf("url = %s, want %s", c.url, tt.wantURL)
+			}
+			if c.IsTestMode() != tt.wantTest {
+				t.Errorf("IsTestMode = %v, want %v", c.IsTestMode(), tt.wantTest)
+			}
+		})
+	}
+}
+
+func TestNewClientCustomWithoutURL(t *testing.T) {
+	if _, err := NewClient("my_id", "my_key", WithEnvironment(Custom)); err == nil {
+		t.Error("NewClient returned nil error for custom environment without URL")
+	}
+}
+
+func TestNewVIESClientEnvironment(t *testing.T) {
+	if c := NewVIESClient("", ""); !c.IsTestMode() || c.creds.ID != test_id {
+		t.Errorf("NewVIESClient without credentials: test mode %v, id %s; want test mode", c.IsTestMode(), c.creds.ID)
+	}
+	if c := NewVIESClient("my_id", "my_key"); c.IsTestMode() {
+		t.Error("NewVIESClient with credentials is in test mode")
+	}
+
+	// explicit environment disables the silent fallback
+	c := NewVIESClient("my_id", "", WithEnvironment(Production))
+	if c.IsTestMode() || c.url != production_url {
+		t.Errorf("NewVIESClient in production: test mode %v, url %s", c.IsTestMode(), c.url)
+	}
+	if _, e := c.GetAccountStatus(); e == nil || e.Code != CLI_CREDENTIALS {
+		t.Errorf("GetAccountStatus with partial credentials = %v, want CLI_CREDENTIALS", e)
+	}
+}
+
+func TestEnvironmentOptionOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantEnv Environment
+		wantURL string
+	}{
+		{"url last", []Option{WithEnvironment(Test), WithURL("https://gateway.local/api")}, Custom, "https://gateway.local/api"},
+		{"environment last", []Option{WithURL("https://gateway.local/api"), WithEnvironment(Production)}, Production, production_url},
+		{"custom keeps url", []Option{WithURL("https://gateway.local/api"), WithEnvironment(Custom)}, Custom, "https://gateway.local/api"},
+	}
+	for _, tt := range tests {
+		c, err := NewClient("my_id", "my_key", tt.opts...)
+		if err != nil {
+			t.Fatalf("%s: NewClient error = %v", tt.name, err)
+		}
+		if c.env != tt.wantEnv || c.url != tt.wantURL {
+			t.Errorf("%s: environment = %v, url = %s; want %v, %s", tt.name, c.env, c.url, tt.wantEnv, tt.wantURL)
+		}
+	}
+}
+
+func TestSetUrlEnvironment(t *testing.T) {
+	c := NewVIESClient("", "")
+	c.SetUrl("https://gateway.local/api")
+	if c.env != Custom {
+		t.Errorf("after SetUrl environment = %v, want custom", c.env)
+	}
+	// the test credentials are still sent
+	if !c.IsTestMode() {
+		t.Error("after SetUrl IsTestMode = false, want true for test credentials")
+	}
+	c = NewVIESClient("my_id", "my_key")
+	c.SetUrl("https://gateway.local/api")
+	if c.IsTestMode() {
+		t.Error("IsTestMode = true for own credentials on custom URL")
+	}
+}
+
+func TestWithoutTestMode(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+	}))
+	defer server.Close()
+
+	c := NewVIESClient("", "", WithoutTestMode())
+	c.SetUrl(server.URL)
+
+	_, e := c.GetVIESData("PL7272445205")
+	if e == nil || e.Code != TEST_MODE || !errors.Is(e, ErrTestMode) {
+		t.Errorf("GetVIESData = %v, want TEST_MODE", e)
+	}
+	if hits != 0 {
+		t.Errorf("service hits = %d, want 0", hits)
+	}
+}
+
+func TestServiceTestMode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<result><error><code>33</code><description>Test mode only</description></error></result>`))
+	}))
+	defer server.Close()
+
+	c, _ := NewClient("my_id", "my_key", WithURL(server.URL))
+	_, e := c.GetAccountStatus()
+	if !errors.Is(e, ErrTestMode) {
+		t.Errorf("GetAccountStatus = %v, want ErrTestMode", e)
+	}
+	if errors.Is(&ViesError{Code: MAINTENANCE}, ErrTestMode) {
+		t.Error("MAINTENANCE error reported as ErrTestMode")
+	}
+}
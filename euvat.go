@@ -15,7 +15,7 @@ func (e *EUVAT) normalize(number string) (string, bool) {
 		return "", false
 	}
 	number = strings.NewReplacer("-", "", " ", "").Replace(number)
-	if !regexp.MustCompile(`[A-Z]{2}[A-Z0-9+*]{2,12}`).MatchString(number) {
+	if !regexp.MustCompile(`^[A-Z]{2}[A-Z0-9+*]{2,12}$`).MatchString(number) {
 		return "", false
 	}
 	return number, true
@@ -33,7 +33,7 @@ func (e *EUVAT) isValid(number string) bool {
 	if !ok {
 		return false
 	}
-	if !regexp.MustCompile(pattern).MatchString(number) {
+	if !regexp.MustCompile("^" + pattern + "$").MatchString(number) {
 		return false
 	}
 
@@ -44,6 +44,47 @@ func (e *EUVAT) isValid(number string) bool {
 	return true
 }
 
+// Builds VAT number from separate country code and number, which may repeat
+// the prefix. Returns false if the country is not a VIES member state.
+func (e *EUVAT) join(countryCode, number string) (string, bool) {
+
+	cc := strings.ToUpper(strings.TrimSpace(countryCode))
+	if cc == "GR" {
+		// VIES uses EL instead of the ISO code for Greece
+		cc = "EL"
+	}
+	if _, ok := cmap[cc]; !ok {
+		return "", false
+	}
+
+	number = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(number))
+	prefixes := []string{cc}
+	if cc == "EL" {
+		prefixes = append(prefixes, "GR")
+	}
+
+	// strip a repeated prefix only if it is not a part of the number itself,
+	// such as the key of FRFR123456789
+	if e.isValid(cc + number) {
+		return cc + number, true
+	}
+	for _, prefix := range prefixes {
+		if stripped, ok := strings.CutPrefix(number, prefix); ok && e.isValid(cc+stripped) {
+			return cc + stripped, true
+		}
+	}
+	return cc + number, true
+}
+
+// Normalize EU VAT number, returns false if the number is invalid
+func NormalizeEUVAT(number string) (string, bool) {
+	e := EUVAT{}
+	if !e.isValid(number) {
+		return "", false
+	}
+	return e.normalize(number)
+}
+
 var cmap = map[string]string{
 	"AT": "ATU\\d{8}",
 	"BE": "BE[0-1]{1}\\d{9}",
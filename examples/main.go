@@ -1,6 +1,8 @@
 package main
 
 import (
+	"os"
+
 	"github.com/glaydus/viesapi"
 )
 
@@ -10,14 +12,14 @@ func main() {
 
 	status, err := client.GetAccountStatus()
 	if status != nil {
-		println(status.String())
+		status.Encode(os.Stdout, viesapi.FormatText)
 	} else {
 		println(err.Error())
 	}
 
 	data, err := client.GetVIESData(nip)
 	if data != nil {
-		println(data.String())
+		data.Encode(os.Stdout, viesapi.FormatText)
 	} else {
 		println(err.Error())
 	}
@@ -0,0 +1,220 @@
+package viesapi
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Verifier taking part in a failover chain
+type Provider struct {
+	Name     string
+	Verifier Verifier
+}
+
+// Failed attempt of a provider
+type ProviderError struct {
+	Provider string
+	Err      *ViesError
+}
+
+// Result of a lookup through FailoverVerifier
+type FailoverResult struct {
+	Data     *VIESData
+	Provider string          // name of the provider that answered
+	Errors   []ProviderError // failures of the providers tried before it
+}
+
+// Health of a provider in the failover chain
+type ProviderHealth struct {
+	Name      string
+	Healthy   bool
+	Failures  int        // consecutive failures
+	LastError *ViesError // nil after success
+	Until     time.Time  // end of the cool-down of an unhealthy provider
+}
+
+// FailoverOption configures FailoverVerifier
+type FailoverOption func(*FailoverVerifier)
+
+// Skip an unhealthy provider for specified period, 1 minute by default
+func WithCooldown(cooldown time.Duration) FailoverOption {
+	return func(f *FailoverVerifier) {
+		f.cooldown = cooldown
+	}
+}
+
+// Mark provider unhealthy after specified number of consecutive failures, 1 by default
+func WithFailureThreshold(n int) FailoverOption {
+	return func(f *FailoverVerifier) {
+		f.threshold = n
+	}
+}
+
+// Fail over on specified error codes instead of CLI_CONNECT, CLI_RATE_LIMIT,
+// CLI_CIRCUIT_OPEN, VIES_SYNC and MAINTENANCE
+func WithFailoverCodes(codes ...int) FailoverOption {
+	return func(f *FailoverVerifier) {
+		f.codes = make(map[int]bool)
+		for _, code := range codes {
+			f.codes[code] = true
+		}
+	}
+}
+
+// FailoverVerifier asks an ordered list of providers until one answers,
+// skipping providers that failed recently
+type FailoverVerifier struct {
+	providers []Provider
+	cooldown  time.Duration
+	threshold int
+	codes     map[int]bool
+	clock     func() time.Time
+
+	mu     sync.Mutex
+	health []ProviderHealth
+}
+
+var _ Verifier = (*FailoverVerifier)(nil)
+
+// Create new FailoverVerifier asking providers in specified order
+func NewFailoverVerifier(providers []Provider, opts ...FailoverOption) *FailoverVerifier {
+	f := &FailoverVerifier{
+		providers: providers,
+		cooldown:  time.Minute,
+		threshold: 1,
+		codes: map[int]bool{
+			CLI_CONNECT:      true,
+			CLI_RATE_LIMIT:   true,
+			CLI_CIRCUIT_OPEN: true,
+			VIES_SYNC:        true,
+			MAINTENANCE:      true,
+		},
+		clock:  time.Now,
+		health: make([]ProviderHealth, len(providers)),
+	}
+	for i, p := range providers {
+		f.health[i] = ProviderHealth{Name: p.Name}
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// Get VIES data for specified number from the first provider able to answer
+func (f *FailoverVerifier) GetVIESDataContext(ctx context.Context, euvat string) (*VIESData, *ViesError) {
+	res, e := f.Verify(ctx, euvat)
+	if e != nil {
+		return nil, e
+	}
+	return res.Data, nil
+}
+
+// Get VIES data for specified number together with the provider that
+// answered. Healthy providers are asked first, unhealthy ones only if all
+// healthy providers failed. Errors other than the failover codes, such as an
+// invalid number, are returned without asking further providers. Degraded
+// data is returned only if no further provider can answer.
+func (f *FailoverVerifier) Verify(ctx context.Context, euvat string) (*FailoverResult, *ViesError) {
+	res := &FailoverResult{}
+	var fallback *FailoverResult
+
+	// ask the provider and report whether the lookup is over
+	try := func(i int) (*ViesError, bool) {
+		p := f.providers[i]
+		data, e := p.Verifier.GetVIESDataContext(ctx, euvat)
+		if e != nil && ctx.Err() != nil {
+			// cancellation says nothing about the provider health
+			return e, true
+		}
+		if e == nil && data.Degraded {
+			// the provider could not reach the service, keep its last known result
+			if fallback == nil {
+				fallback = &FailoverResult{Data: data, Provider: p.Name, Errors: append([]ProviderError(nil), res.Errors...)}
+			}
+			return nil, false
+		}
+		f.record(i, e)
+		if e == nil {
+			res.Data = data
+			res.Provider = p.Name
+			return nil, true
+		}
+		res.Errors = append(res.Errors, ProviderError{Provider: p.Name, Err: e})
+		return e, !f.codes[e.Code]
+	}
+
+	var healthy, unhealthy []int
+	for i := range f.providers {
+		if f.healthy(i) {
+			healthy = append(healthy, i)
+		} else {
+			unhealthy = append(unhealthy, i)
+		}
+	}
+
+	var e *ViesError
+	for _, i := range append(healthy, unhealthy...) {
+		var done bool
+		if e, done = try(i); done {
+			break
+		}
+	}
+	if res.Data == nil && fallback != nil && ctx.Err() == nil && (e == nil || f.codes[e.Code]) {
+		return fallback, nil
+	}
+	if e != nil {
+		return nil, e
+	}
+	if res.Data == nil {
+		return nil, &ViesError{Code: CLI_CONNECT, Description: "No verifier configured"}
+	}
+	return res, nil
+}
+
+// Get health of all providers in chain order
+func (f *FailoverVerifier) Health() []ProviderHealth {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.clock()
+	health := make([]ProviderHealth, len(f.health))
+	for i, h := range f.health {
+		h.Healthy = !now.Before(h.Until)
+		health[i] = h
+	}
+	return health
+}
+
+// Check if provider is out of its cool-down
+func (f *FailoverVerifier) healthy(i int) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return !f.clock().Before(f.health[i].Until)
+}
+
+// Update provider health with result of its lookup
+func (f *FailoverVerifier) record(i int, e *ViesError) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	h := &f.health[i]
+	if e != nil && e.Code == CLI_CIRCUIT_OPEN {
+		// only a single member state is suspended, the provider is fine otherwise
+		return
+	}
+	if e == nil || !f.codes[e.Code] {
+		// the provider answered, even if the number is invalid
+		h.Failures = 0
+		h.LastError = nil
+		h.Until = time.Time{}
+		return
+	}
+
+	h.Failures++
+	h.LastError = e
+	if h.Failures >= f.threshold {
+		h.Until = f.clock().Add(f.cooldown)
+	}
+}
@@ -0,0 +1,177 @@
+package viesapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Verifier returning preset error or valid data
+type stubVerifier struct {
+	code     int
+	degraded bool
+	calls    int
+}
+
+func (s *stubVerifier) GetVIESDataContext(ctx context.Context, euvat string) (*VIESData, *ViesError) {
+	s.calls++
+	if s.code != 0 {
+		return nil, &ViesError{Code: s.code}
+	}
+	return &VIESData{CountryCode: euvat[:2], VATNumber: euvat[2:], Valid: true, Stale: s.degraded, Degraded: s.degraded}, nil
+}
+
+func TestFailoverVerifier(t *testing.T) {
+	primary := &stubVerifier{code: MAINTENANCE}
+	secondary := &stubVerifier{}
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	f := NewFailoverVerifier([]Provider{{"primary", primary}, {"secondary", secondary}}, WithCooldown(time.Minute))
+	f.clock = func() time.Time { return now }
+
+	res, e := f.Verify(context.Background(), "PL7272445205")
+	if e != nil || res.Provider != "secondary" || !res.Data.Valid {
+		t.Fatalf("Verify = %+v, %v; want answer from secondary", res, e)
+	}
+	if len(res.Errors) != 1 || res.Errors[0].Provider != "primary" || res.Errors[0].Err.Code != MAINTENANCE {
+		t.Errorf("Errors = %+v, want MAINTENANCE of primary", res.Errors)
+	}
+
+	health := f.Health()
+	if health[0].Healthy || health[0].Failures != 1 || !health[0].Until.Equal(now.Add(time.Minute)) || !health[1].Healthy {
+		t.Errorf("Health = %+v, want primary cooling down", health)
+	}
+
+	// the primary is skipped during its cool-down
+	f.Verify(context.Background(), "PL7272445205")
+	if primary.calls != 1 || secondary.calls != 2 {
+		t.Errorf("calls = %d, %d; want 1, 2", primary.calls, secondary.calls)
+	}
+
+	// and asked again once it is over
+	now = now.Add(time.Minute)
+	primary.code = 0
+	res, _ = f.Verify(context.Background(), "PL7272445205")
+	if res.Provider != "primary" || !f.Health()[0].Healthy || f.Health()[0].LastError != nil {
+		t.Errorf("Verify after cool-down answered by %s, want primary", res.Provider)
+	}
+}
+
+func TestFailoverVerifierErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		codes     []int
+		want      int
+		wantCalls []int
+	}{
+		{"invalid number stops chain", []int{EUVAT_BAD, 0}, EUVAT_BAD, []int{1, 0}},
+		{"all down", []int{CLI_CONNECT, VIES_SYNC}, VIES_SYNC, []int{1, 1}},
+		{"not failover code", []int{CLI_AUTH, 0}, CLI_AUTH, []int{1, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := &stubVerifier{code: tt.codes[0]}, &stubVerifier{code: tt.codes[1]}
+			f := NewFailoverVerifier([]Provider{{"a", a}, {"b", b}})
+
+			_, e := f.GetVIESDataContext(context.Background(), "PL7272445205")
+			if e == nil || e.Code != tt.want {
+				t.Errorf("error = %v, want code %d", e, tt.want)
+			}
+			if a.calls != tt.wantCalls[0] || b.calls != tt.wantCalls[1] {
+				t.Errorf("calls = %d, %d; want %v", a.calls, b.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestFailoverVerifierAllUnhealthy(t *testing.T) {
+	a, b := &stubVerifier{code: CLI_CONNECT}, &stubVerifier{code: CLI_CONNECT}
+	f := NewFailoverVerifier([]Provider{{"a", a}, {"b", b}}, WithFailureThreshold(2))
+
+	f.Verify(context.Background(), "PL7272445205")
+	if h := f.Health(); !h[0].Healthy || h[0].Failures != 1 {
+		t.Errorf("Health after one failure = %+v, want healthy below threshold", h[0])
+	}
+	f.Verify(context.Background(), "PL7272445205")
+	if h := f.Health(); h[0].Healthy || h[1].Healthy {
+		t.Errorf("Health = %+v, want both unhealthy", h)
+	}
+
+	// unhealthy providers are still asked as the last resort
+	b.code = 0
+	res, e := f.Verify(context.Background(), "PL7272445205")
+	if e != nil || res.Provider != "b" {
+		t.Errorf("Verify = %+v, %v; want answer from b", res, e)
+	}
+}
+
+func TestFailoverDegraded(t *testing.T) {
+	tests := []struct {
+		name      string
+		fallback  int
+		want      string
+		wantError int
+	}{
+		{"fresh answer preferred", 0, "ecvies", 0},
+		{"degraded answer kept", MAINTENANCE, "viesapi", 0},
+		{"invalid number reported", EUVAT_BAD, "", EUVAT_BAD},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := &stubVerifier{degraded: true}
+			fallback := &stubVerifier{code: tt.fallback}
+			f := NewFailoverVerifier([]Provider{{"viesapi", primary}, {"ecvies", fallback}})
+
+			res, e := f.Verify(context.Background(), "PL7272445205")
+			if tt.wantError != 0 {
+				if e == nil || e.Code != tt.wantError {
+					t.Errorf("error = %v, want code %d", e, tt.wantError)
+				}
+				return
+			}
+			if e != nil || res.Provider != tt.want || res.Data.Degraded != (tt.want == "viesapi") {
+				t.Errorf("Verify = %+v, %v; want answer from %s", res, e, tt.want)
+			}
+			if fallback.calls != 1 {
+				t.Errorf("fallback calls = %d, want 1", fallback.calls)
+			}
+		})
+	}
+}
+
+func TestFailoverCircuitOpen(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write([]byte(`<result><error><code>23</code><description>Member state unavailable</description></error></result>`))
+	}))
+	defer server.Close()
+
+	breaker := NewCircuitBreaker(1, time.Minute)
+	client := NewVIESClient("test_id", "test_key", WithCircuitBreaker(breaker))
+	client.SetUrl(server.URL)
+	fallback := &stubVerifier{}
+
+	f := NewFailoverVerifier([]Provider{{"viesapi", client}, {"ecvies", fallback}}, WithFailureThreshold(2))
+	for i, want := range []int{VIES_SYNC, CLI_CIRCUIT_OPEN, CLI_CIRCUIT_OPEN} {
+		res, e := f.Verify(context.Background(), "IT12345678901")
+		if e != nil || res.Provider != "ecvies" {
+			t.Fatalf("Verify %d = %+v, %v; want answer from ecvies", i+1, res, e)
+		}
+		if len(res.Errors) != 1 || res.Errors[0].Err.Code != want {
+			t.Errorf("Verify %d errors = %+v, want code %d", i+1, res.Errors, want)
+		}
+	}
+	if hits != 1 || breaker.State("IT") != CircuitOpen {
+		t.Errorf("hits = %d, state %v; want 1, open", hits, breaker.State("IT"))
+	}
+
+	// the open circuit does not count against the provider health
+	if h := f.Health()[0]; !h.Healthy || h.Failures != 1 {
+		t.Errorf("viesapi health = %+v, want healthy with 1 failure", h)
+	}
+}
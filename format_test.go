@@ -0,0 +1,129 @@
+package viesapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Serve response fixtures in the format requested by the Accept header
+func newFixtureServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ext, typ := "xml", "application/xml"
+		if r.Header.Get("Accept") == "application/json" {
+			ext, typ = "json", "application/json"
+		}
+
+		name := "account"
+		if strings.HasPrefix(r.URL.Path, "/get/vies/euvat/") {
+			name = "vies"
+			if r.URL.Path != "/get/vies/euvat/PL7272445205" {
+				name = "error"
+			}
+		}
+
+		b, err := os.ReadFile(filepath.Join("testdata", "responses", name+"."+ext))
+		if err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", typ)
+		w.Write(b)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestResponseFormats(t *testing.T) {
+	validTo := time.Date(2030, 1, 1, 0, 0, 0, 0, time.FixedZone("", 3600))
+	wantData := &VIESData{
+		UID:               "a7a6c2e1-8a3f-4c9e-9f3b-5d1e2c3b4a59",
+		CountryCode:       "PL",
+		VATNumber:         "7272445205",
+		Valid:             true,
+		TraderName:        "NETCAT Sp. z o.o.",
+		TraderCompanyType: "---",
+		TraderAddress:     "ul. Żółkiewskiego 7\n90-001 Łódź",
+		ID:                "7c2a1b9e0d",
+		Date:              "2024-01-15",
+		Source:            "http://ec.europa.eu",
+	}
+	wantStatus := &AccountStatus{
+		UID:               "0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0",
+		Type:              "business",
+		ValidTo:           &validTo,
+		BillingPlanName:   "Pro",
+		SubscriptionPrice: 49.5,
+		ItemPrice:         0.1,
+		ItemPriceStatus:   0.05,
+		Limit:             1000,
+		RequestDelay:      1,
+		DomainLimit:       5,
+		OverPlanAllowed:   true,
+		ExcelAddIn:        true,
+		CLI:               true,
+		Stats:             true,
+		FuncGetVIESData:   true,
+		VIESDataCount:     42,
+		TotalCount:        50,
+	}
+
+	server := newFixtureServer(t)
+	for _, format := range []ResponseFormat{ResponseXML, ResponseJSON} {
+		t.Run(format.String(), func(t *testing.T) {
+			c := NewVIESClient("test_id", "test_key", WithResponseFormat(format))
+			c.SetUrl(server.URL)
+
+			data, e := c.GetVIESData("PL7272445205")
+			if e != nil {
+				t.Fatalf("GetVIESData returned error: %v", e)
+			}
+			if !reflect.DeepEqual(data, wantData) {
+				t.Errorf("VIES data = %+v, want %+v", data, wantData)
+			}
+
+			status, e := c.GetAccountStatus()
+			if e != nil {
+				t.Fatalf("GetAccountStatus returned error: %v", e)
+			}
+			if !status.ValidTo.Equal(validTo) {
+				t.Errorf("ValidTo = %v, want %v", status.ValidTo, validTo)
+			}
+			status.ValidTo = &validTo
+			if !reflect.DeepEqual(status, wantStatus) {
+				t.Errorf("account status = %+v, want %+v", status, wantStatus)
+			}
+
+			_, e = c.GetVIESData("DE123456789")
+			if e == nil || e.Code != EUVAT_BAD || e.Description != "EU VAT ID is invalid" {
+				t.Errorf("error = %v, want EUVAT_BAD", e)
+			}
+		})
+	}
+}
+
+func TestResponseFormatSniffing(t *testing.T) {
+	// a proxy ignoring the Accept header must not break the client
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := os.ReadFile(filepath.Join("testdata", "responses", "vies.xml"))
+		w.Write(b)
+	}))
+	defer server.Close()
+
+	c := NewVIESClient("test_id", "test_key", WithResponseFormat(ResponseJSON))
+	c.SetUrl(server.URL)
+
+	if data, e := c.GetVIESData("PL7272445205"); e != nil || !data.Valid {
+		t.Errorf("GetVIESData = %v, %v; want valid result", data, e)
+	}
+}
+
+func TestResponseFormatUnknown(t *testing.T) {
+	if _, err := NewClient("id", "key", WithResponseFormat(ResponseFormat(7))); err == nil || !strings.Contains(err.Error(), "ResponseFormat(7)") {
+		t.Errorf("NewClient error = %v, want unknown response format", err)
+	}
+}
@@ -0,0 +1,82 @@
+package gateway
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/glaydus/viesapi"
+)
+
+// Verify MAC authorization header of the request and return caller id
+func (g *Gateway) authenticate(r *http.Request) (string, *viesapi.ViesError) {
+
+	header := r.Header.Get("Authorization")
+	id := viesapi.AuthorizationID(header)
+
+	g.mu.Lock()
+	key, ok := g.callers[id]
+	g.mu.Unlock()
+	if !ok {
+		return "", &viesapi.ViesError{Code: viesapi.DB_AUTH_KEYID_VALUE, Description: "Unknown key identifier"}
+	}
+
+	// the client signs the path of its own url, which may have been stripped by a mux
+	u := url.URL{Scheme: "http", Host: r.Host, Path: r.URL.Path}
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+	if ru, err := url.ParseRequestURI(r.RequestURI); err == nil {
+		u.Path = ru.Path
+	}
+	if g.public != "" {
+		// behind a proxy the client signs the public scheme and host
+		pu, err := url.Parse(g.public)
+		if err != nil {
+			return "", &viesapi.ViesError{Code: viesapi.CLI_EXCEPTION, Description: "Invalid gateway public URL"}
+		}
+		u.Scheme, u.Host = pu.Scheme, pu.Host
+	}
+
+	ts, err := viesapi.NewSigner(id, key, nil, nil).Verify(r.Method, u.String(), header)
+	if errors.Is(err, viesapi.ErrMAC) {
+		return "", &viesapi.ViesError{Code: viesapi.AUTH_MAC, Description: "Invalid authorization MAC"}
+	}
+	if err != nil {
+		return "", &viesapi.ViesError{Code: viesapi.ACCESS_DENIED, Description: "Invalid authorization header"}
+	}
+	if d := time.Since(ts); d > g.skew || d < -g.skew {
+		return "", &viesapi.ViesError{Code: viesapi.AUTH_TIMESTAMP, Description: "Authorization timestamp out of range"}
+	}
+	if !g.remember(id, viesapi.AuthorizationNonce(header), ts) {
+		return "", &viesapi.ViesError{Code: viesapi.ACCESS_DENIED, Description: "Authorization nonce already used"}
+	}
+
+	return id, nil
+}
+
+// Remember nonce of the caller until its timestamp leaves the accepted window,
+// returns false if the same signed request was seen before
+func (g *Gateway) remember(id, nonce string, ts time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(g.swept) > g.skew {
+		for key, expiry := range g.nonces {
+			if now.After(expiry) {
+				delete(g.nonces, key)
+			}
+		}
+		g.swept = now
+	}
+
+	key := id + "\n" + strconv.FormatInt(ts.Unix(), 10) + "\n" + nonce
+	if expiry, ok := g.nonces[key]; ok && !now.After(expiry) {
+		return false
+	}
+	g.nonces[key] = ts.Add(g.skew)
+	return true
+}
@@ -0,0 +1,192 @@
+// Package gateway implements a caching VIES API proxy for internal callers.
+//
+// A Gateway exposes the same endpoints and XML responses as the VIES API
+// service, so any VIESClient can be pointed at it with SetUrl. Callers are
+// authenticated with their own id and key, while all lookups share a single
+// upstream VIESClient.
+package gateway
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glaydus/viesapi"
+)
+
+const (
+	pathVIESData      = "/get/vies/euvat/"
+	pathAccountStatus = "/check/account/status"
+)
+
+// Option configures optional Gateway behaviour
+type Option func(*Gateway)
+
+// Accept authorization timestamps that differ from the local clock by at most skew
+func WithTimestampSkew(skew time.Duration) Option {
+	return func(g *Gateway) {
+		g.skew = skew
+	}
+}
+
+// Verify signatures against specified public base URL, such as
+// https://vies.example.com, instead of the scheme and host of the request.
+// Use it when TLS is terminated by a proxy or load balancer in front of the gateway.
+func WithPublicURL(base string) Option {
+	return func(g *Gateway) {
+		g.public = base
+	}
+}
+
+// Usage counters of a single caller
+type Usage struct {
+	VIESDataCount int       `json:"vies_data_count"`
+	TotalCount    int       `json:"total_count"`
+	ErrorCount    int       `json:"error_count"`
+	LastRequest   time.Time `json:"last_request"`
+}
+
+// Gateway is an HTTP handler serving VIES API requests from a shared upstream client
+type Gateway struct {
+	client  *viesapi.VIESClient
+	skew    time.Duration
+	public  string
+	mu      sync.Mutex
+	callers map[string]string
+	usage   map[string]*Usage
+	nonces  map[string]time.Time // expiry of nonces seen within the skew window
+	swept   time.Time
+}
+
+// Create new Gateway forwarding lookups to specified upstream client
+func New(client *viesapi.VIESClient, opts ...Option) *Gateway {
+	g := &Gateway{
+		client:  client,
+		skew:    5 * time.Minute,
+		callers: make(map[string]string),
+		usage:   make(map[string]*Usage),
+		nonces:  make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Register caller with specified id and key
+func (g *Gateway) AddCaller(id, key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.callers[id] = key
+}
+
+// Unregister caller with specified id
+func (g *Gateway) RemoveCaller(id string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.callers, id)
+}
+
+// Get snapshot of usage counters keyed by caller id
+func (g *Gateway) Usage() map[string]Usage {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	res := make(map[string]Usage, len(g.usage))
+	for id, u := range g.usage {
+		res[id] = *u
+	}
+	return res
+}
+
+// Serve VIES API request
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := r.URL.Path
+	i := strings.Index(path, pathVIESData)
+	if i < 0 && !strings.HasSuffix(path, pathAccountStatus) {
+		http.NotFound(w, r)
+		return
+	}
+
+	id, e := g.authenticate(r)
+	if e != nil {
+		g.write(w, &result{Error: e})
+		return
+	}
+
+	// count the request first so that reported account counters include it
+	g.count(id, i >= 0)
+
+	var res result
+	if i >= 0 {
+		if number, ok := viesapi.NormalizeEUVAT(path[i+len(pathVIESData):]); ok {
+			res.VIES, res.Error = g.client.GetVIESDataContext(r.Context(), number)
+		} else {
+			res.Error = &viesapi.ViesError{Code: viesapi.CLI_EUVAT, Description: "EU VAT ID is invalid"}
+		}
+	} else {
+		res.Account, res.Error = g.getAccountStatus(r.Context(), id)
+	}
+	if res.Error != nil {
+		g.fail(id)
+	}
+	g.write(w, &res)
+}
+
+// Get upstream account status with request counters of specified caller
+func (g *Gateway) getAccountStatus(ctx context.Context, id string) (*account, *viesapi.ViesError) {
+	status, e := g.client.GetAccountStatusContext(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	g.mu.Lock()
+	u := *g.usage[id]
+	g.mu.Unlock()
+
+	return newAccount(status, &u), nil
+}
+
+// Count request of specified caller
+func (g *Gateway) count(id string, vies bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	u, ok := g.usage[id]
+	if !ok {
+		u = &Usage{}
+		g.usage[id] = u
+	}
+	if vies {
+		u.VIESDataCount++
+	}
+	u.TotalCount++
+	u.LastRequest = time.Now()
+}
+
+// Count failed request of specified caller
+func (g *Gateway) fail(id string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.usage[id].ErrorCount++
+}
+
+// Write XML response
+func (g *Gateway) write(w http.ResponseWriter, res *result) {
+	if res.Error == nil {
+		res.Error = &viesapi.ViesError{}
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=UTF-8")
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(res)
+}
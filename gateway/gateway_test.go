@@ -0,0 +1,347 @@
+package gateway
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/glaydus/viesapi"
+)
+
+const viesXML = `<?xml version="1.0" encoding="UTF-8"?>
+<result>
+	<vies>
+		<uid>test-uid</uid>
+		<countryCode>PL</countryCode>
+		<vatNumber>7272445205</vatNumber>
+		<valid>true</valid>
+		<traderName>Test Company</traderName>
+		<date>2024-01-15</date>
+	</vies>
+	<error>
+		<code>0</code>
+		<description></description>
+	</error>
+</result>`
+
+const accountXML = `<?xml version="1.0" encoding="UTF-8"?>
+<result>
+	<account>
+		<uid>account-uid</uid>
+		<type>premium</type>
+		<validTo>2024-12-31T23:59:59+01:00</validTo>
+		<billingPlan>
+			<name>Premium</name>
+			<limit>10000</limit>
+		</billingPlan>
+		<requests>
+			<viesData>500</viesData>
+			<total>700</total>
+		</requests>
+	</account>
+	<error>
+		<code>0</code>
+		<description></description>
+	</error>
+</result>`
+
+// Start upstream stand-in and gateway, returning gateway URL and upstream hit counter
+func newTestGateway(t *testing.T, upstream http.HandlerFunc, opts ...viesapi.Option) (*Gateway, string) {
+	t.Helper()
+
+	up := httptest.NewServer(upstream)
+	t.Cleanup(up.Close)
+
+	client := viesapi.NewVIESClient("upstream_id", "upstream_key", opts...)
+	client.SetUrl(up.URL)
+
+	g := New(client)
+	g.AddCaller("caller", "secret")
+
+	srv := httptest.NewServer(g)
+	t.Cleanup(srv.Close)
+
+	return g, srv.URL
+}
+
+func TestGatewayGetVIESData(t *testing.T) {
+	var hits int32
+	g, url := newTestGateway(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write([]byte(viesXML))
+	}, viesapi.WithCache(viesapi.NewMemoryCache(10), time.Minute))
+
+	c := viesapi.NewVIESClient("caller", "secret")
+	c.SetUrl(url)
+
+	for i := 0; i < 3; i++ {
+		data, err := c.GetVIESData("PL7272445205")
+		if err != nil {
+			t.Fatalf("GetVIESData returned error: %v", err)
+		}
+		if data.TraderName != "Test Company" {
+			t.Errorf("TraderName = %s, want Test Company", data.TraderName)
+		}
+	}
+	if hits != 1 {
+		t.Errorf("upstream hits = %d, want 1", hits)
+	}
+
+	u := g.Usage()["caller"]
+	if u.VIESDataCount != 3 || u.TotalCount != 3 || u.ErrorCount != 0 {
+		t.Errorf("usage = %+v, want 3 lookups without errors", u)
+	}
+}
+
+func TestGatewayCoalescing(t *testing.T) {
+	var hits int32
+	release := make(chan struct{})
+	arrived := make(chan struct{}, 5)
+	_, url := newTestGateway(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-release
+		w.Write([]byte(viesXML))
+	},
+		viesapi.WithCache(viesapi.NewMemoryCache(10), time.Minute),
+		// the cache lookup precedes joining the shared call
+		viesapi.WithTrace(&viesapi.ClientTrace{CacheLookup: func(string, bool) { arrived <- struct{}{} }}),
+	)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// separate clients so that lookups are not shared before the gateway
+			c := viesapi.NewVIESClient("caller", "secret")
+			c.SetUrl(url)
+			if _, err := c.GetVIESData("PL7272445205"); err != nil {
+				t.Errorf("GetVIESData returned error: %v", err)
+			}
+		}()
+	}
+
+	for i := 0; i < 5; i++ {
+		<-arrived
+	}
+	close(release)
+	wg.Wait()
+
+	if hits != 1 {
+		t.Errorf("upstream hits = %d, want 1", hits)
+	}
+}
+
+func TestGatewayAuthentication(t *testing.T) {
+	g, url := newTestGateway(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(viesXML))
+	})
+
+	tests := []struct {
+		name string
+		id   string
+		key  string
+		code int
+	}{
+		{"unknown id", "other", "secret", viesapi.DB_AUTH_KEYID_VALUE},
+		{"wrong key", "caller", "wrong", viesapi.AUTH_MAC},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := viesapi.NewVIESClient(tt.id, tt.key)
+			c.SetUrl(url)
+
+			data, err := c.GetVIESData("PL7272445205")
+			if data != nil {
+				t.Error("GetVIESData should return nil for rejected caller")
+			}
+			if err == nil || err.Code != tt.code {
+				t.Errorf("error = %v, want code %d", err, tt.code)
+			}
+		})
+	}
+
+	if len(g.Usage()) != 0 {
+		t.Errorf("usage = %v, want no counted callers", g.Usage())
+	}
+}
+
+func TestGatewayAccountStatus(t *testing.T) {
+	_, url := newTestGateway(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/check/account/status" {
+			w.Write([]byte(accountXML))
+			return
+		}
+		w.Write([]byte(viesXML))
+	})
+
+	c := viesapi.NewVIESClient("caller", "secret")
+	c.SetUrl(url)
+
+	if _, err := c.GetVIESData("PL7272445205"); err != nil {
+		t.Fatalf("GetVIESData returned error: %v", err)
+	}
+
+	status, err := c.GetAccountStatus()
+	if err != nil {
+		t.Fatalf("GetAccountStatus returned error: %v", err)
+	}
+	if status.UID != "account-uid" || status.Limit != 10000 {
+		t.Errorf("status = %v, want upstream account", status)
+	}
+	if status.VIESDataCount != 1 || status.TotalCount != 2 {
+		t.Errorf("counters = %d/%d, want caller counters 1/2", status.VIESDataCount, status.TotalCount)
+	}
+	if status.ValidTo == nil || status.ValidTo.Year() != 2024 {
+		t.Errorf("ValidTo = %v, want 2024-12-31", status.ValidTo)
+	}
+}
+
+func TestGatewayNotFound(t *testing.T) {
+	_, url := newTestGateway(t, func(w http.ResponseWriter, r *http.Request) {})
+
+	res, err := http.Get(url + "/get/vies/nip/7272445205")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", res.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestGatewayReplay(t *testing.T) {
+	_, url := newTestGateway(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(viesXML))
+	})
+
+	target := url + "/get/vies/euvat/PL7272445205"
+	header, err := viesapi.NewSigner("caller", "secret", nil, nil).Sign("GET", target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []int{0, viesapi.ACCESS_DENIED} {
+		req, _ := http.NewRequest("GET", target, nil)
+		req.Header.Set("Authorization", header)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body struct {
+			Error viesapi.ViesError `xml:"error"`
+		}
+		xml.NewDecoder(res.Body).Decode(&body)
+		res.Body.Close()
+		if body.Error.Code != want {
+			t.Errorf("request %d code = %d, want %d", i+1, body.Error.Code, want)
+		}
+	}
+}
+
+// Send GET request to target signed by caller for signed url and return the error code of the response
+func signedGet(t *testing.T, target, signed, id, key string) int {
+	t.Helper()
+
+	header, err := viesapi.NewSigner(id, key, nil, nil).Sign("GET", signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("GET", target, nil)
+	req.Header.Set("Authorization", header)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		Error viesapi.ViesError `xml:"error"`
+	}
+	if err := xml.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	return body.Error.Code
+}
+
+func TestGatewayInvalidNumber(t *testing.T) {
+	hits := 0
+	g, url := newTestGateway(t, func(w http.ResponseWriter, r *http.Request) {
+		hits++
+	})
+
+	for _, number := range []string{"XX123", "PL7272445205x", "PL72724452"} {
+		target := url + "/get/vies/euvat/" + number
+		if code := signedGet(t, target, target, "caller", "secret"); code != viesapi.CLI_EUVAT {
+			t.Errorf("%s: code = %d, want %d", number, code, viesapi.CLI_EUVAT)
+		}
+		// unauthenticated callers learn nothing about the number
+		if code := signedGet(t, target, target, "other", "secret"); code != viesapi.DB_AUTH_KEYID_VALUE {
+			t.Errorf("%s: unknown caller code = %d, want %d", number, code, viesapi.DB_AUTH_KEYID_VALUE)
+		}
+	}
+	if hits != 0 {
+		t.Errorf("upstream hits = %d, want 0", hits)
+	}
+	if u := g.Usage()["caller"]; u.VIESDataCount != 3 || u.ErrorCount != 3 {
+		t.Errorf("usage = %+v, want 3 failed lookups", u)
+	}
+}
+
+func TestGatewayPublicURL(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(viesXML))
+	}))
+	t.Cleanup(up.Close)
+	client := viesapi.NewVIESClient("upstream_id", "upstream_key")
+	client.SetUrl(up.URL)
+
+	g := New(client, WithPublicURL("https://vies.example.com"))
+	g.AddCaller("caller", "secret")
+	srv := httptest.NewServer(g)
+	t.Cleanup(srv.Close)
+
+	// the proxy forwards the request signed for the public url over plain http
+	const path = "/get/vies/euvat/PL7272445205"
+	if code := signedGet(t, srv.URL+path, "https://vies.example.com"+path, "caller", "secret"); code != 0 {
+		t.Errorf("public url code = %d, want 0", code)
+	}
+	if code := signedGet(t, srv.URL+path, srv.URL+path, "caller", "secret"); code != viesapi.AUTH_MAC {
+		t.Errorf("internal url code = %d, want %d", code, viesapi.AUTH_MAC)
+	}
+}
+
+func TestGatewayDegraded(t *testing.T) {
+	var failing atomic.Bool
+	_, url := newTestGateway(t, func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.Write([]byte(`<result><error><code>` + strconv.Itoa(viesapi.VIES_SYNC) + `</code><description>Member state down</description></error></result>`))
+			return
+		}
+		w.Write([]byte(viesXML))
+	},
+		viesapi.WithCache(viesapi.NewMemoryCache(10), time.Nanosecond),
+		viesapi.WithStalePolicy(viesapi.StalePolicy{MaxStale: time.Hour}),
+	)
+
+	c := viesapi.NewVIESClient("caller", "secret")
+	c.SetUrl(url)
+
+	data, err := c.GetVIESData("PL7272445205")
+	if err != nil || data.Stale || data.Degraded {
+		t.Fatalf("GetVIESData = %+v, %v; want fresh data", data, err)
+	}
+
+	// the caller learns that the upstream result is a fallback
+	failing.Store(true)
+	data, err = c.GetVIESData("PL7272445205")
+	if err != nil || data.TraderName != "Test Company" || !data.Stale || !data.Degraded {
+		t.Errorf("GetVIESData = %+v, %v; want degraded data", data, err)
+	}
+}
@@ -0,0 +1,75 @@
+package gateway
+
+import (
+	"encoding/xml"
+
+	"github.com/glaydus/viesapi"
+)
+
+const dateTimeLayout = "2006-01-02T15:04:05-07:00"
+
+// Response document in the format of the VIES API service
+type result struct {
+	XMLName xml.Name           `xml:"result"`
+	VIES    *viesapi.VIESData  `xml:"vies,omitempty"`
+	Account *account           `xml:"account,omitempty"`
+	Error   *viesapi.ViesError `xml:"error"`
+}
+
+type account struct {
+	UID         string      `xml:"uid"`
+	Type        string      `xml:"type"`
+	ValidTo     string      `xml:"validTo"`
+	BillingPlan billingPlan `xml:"billingPlan"`
+	Requests    struct {
+		VIESDataCount int `xml:"viesData"`
+		TotalCount    int `xml:"total"`
+	} `xml:"requests"`
+}
+
+type billingPlan struct {
+	Name              string  `xml:"name"`
+	SubscriptionPrice float64 `xml:"subscriptionPrice"`
+	ItemPrice         float64 `xml:"itemPrice"`
+	ItemPriceStatus   float64 `xml:"itemPriceCheckStatus"`
+	Limit             int     `xml:"limit"`
+	RequestDelay      int     `xml:"requestDelay"`
+	DomainLimit       int     `xml:"domainLimit"`
+	OverPlanAllowed   bool    `xml:"overplanAllowed"`
+	ExcelAddIn        bool    `xml:"excelAddin"`
+	App               bool    `xml:"app"`
+	CLI               bool    `xml:"cli"`
+	Stats             bool    `xml:"stats"`
+	Monitor           bool    `xml:"monitor"`
+	FuncGetVIESData   bool    `xml:"funcGetVIESData"`
+}
+
+// Convert upstream account status to response form with counters of the caller
+func newAccount(s *viesapi.AccountStatus, u *Usage) *account {
+	a := &account{
+		UID:  s.UID,
+		Type: s.Type,
+		BillingPlan: billingPlan{
+			Name:              s.BillingPlanName,
+			SubscriptionPrice: s.SubscriptionPrice,
+			ItemPrice:         s.ItemPrice,
+			ItemPriceStatus:   s.ItemPriceStatus,
+			Limit:             s.Limit,
+			RequestDelay:      s.RequestDelay,
+			DomainLimit:       s.DomainLimit,
+			OverPlanAllowed:   s.OverPlanAllowed,
+			ExcelAddIn:        s.ExcelAddIn,
+			App:               s.App,
+			CLI:               s.CLI,
+			Stats:             s.Stats,
+			Monitor:           s.Monitor,
+			FuncGetVIESData:   s.FuncGetVIESData,
+		},
+	}
+	if s.ValidTo != nil {
+		a.ValidTo = s.ValidTo.Format(dateTimeLayout)
+	}
+	a.Requests.VIESDataCount = u.VIESDataCount
+	a.Requests.TotalCount = u.TotalCount
+	return a
+}
@@ -0,0 +1,118 @@
+package viesapi
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// VIES data result stored in verification history
+type HistoryRecord struct {
+	Number  string    `json:"number"`
+	Checked time.Time `json:"checked"`
+	Data    VIESData  `json:"data"`
+}
+
+// Criteria of history query, zero values match everything
+type HistoryQuery struct {
+	Number string    // normalized EU VAT number
+	From   time.Time // inclusive
+	To     time.Time // inclusive
+	Valid  *bool
+	Newest bool // order newest first instead of oldest first
+	Limit  int
+}
+
+// HistoryStore persists VIES data results for later queries
+type HistoryStore interface {
+	// Save stores single result
+	Save(ctx context.Context, rec *HistoryRecord) error
+	// Query returns results matching the criteria ordered by check time
+	Query(ctx context.Context, q HistoryQuery) ([]HistoryRecord, error)
+}
+
+// Get the last result for specified number checked at or before t, nil if there is none
+func HistoryAt(ctx context.Context, store HistoryStore, number string, t time.Time) (*HistoryRecord, error) {
+	recs, err := store.Query(ctx, HistoryQuery{Number: number, To: t, Newest: true, Limit: 1})
+	if err != nil || len(recs) == 0 {
+		return nil, err
+	}
+	return &recs[0], nil
+}
+
+// Save successful result in the history store, failures are logged and do not fail the lookup
+func (c *VIESClient) remember(ctx context.Context, number string, data *VIESData) {
+	if c.history == nil {
+		return
+	}
+
+	rec := &HistoryRecord{Number: number, Checked: c.clock(), Data: *data}
+	if err := c.history.Save(ctx, rec); err != nil && c.logger != nil {
+		c.logger.LogAttrs(ctx, slog.LevelError, "viesapi history failed", slog.String("error", err.Error()))
+	}
+}
+
+// In-memory HistoryStore
+type MemoryHistory struct {
+	mu   sync.Mutex
+	recs []HistoryRecord
+}
+
+// Create new empty MemoryHistory
+func NewMemoryHistory() *MemoryHistory {
+	return &MemoryHistory{}
+}
+
+// Store single result
+func (m *MemoryHistory) Save(ctx context.Context, rec *HistoryRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// keep records ordered by check time
+	i := sort.Search(len(m.recs), func(i int) bool {
+		return m.recs[i].Checked.After(rec.Checked)
+	})
+	m.recs = append(m.recs, HistoryRecord{})
+	copy(m.recs[i+1:], m.recs[i:])
+	m.recs[i] = *rec
+	return nil
+}
+
+// Get results matching the criteria ordered by check time
+func (m *MemoryHistory) Query(ctx context.Context, q HistoryQuery) ([]HistoryRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var res []HistoryRecord
+	for i := range m.recs {
+		rec := m.recs[i]
+		if q.Newest {
+			rec = m.recs[len(m.recs)-1-i]
+		}
+		if !q.matches(&rec) {
+			continue
+		}
+		res = append(res, rec)
+		if q.Limit > 0 && len(res) == q.Limit {
+			break
+		}
+	}
+	return res, nil
+}
+
+// Check if record matches the criteria
+func (q *HistoryQuery) matches(rec *HistoryRecord) bool {
+	switch {
+	case q.Number != "" && rec.Number != q.Number:
+		return false
+	case !q.From.IsZero() && rec.Checked.Before(q.From):
+		return false
+	case !q.To.IsZero() && rec.Checked.After(q.To):
+		return false
+	case q.Valid != nil && rec.Data.Valid != *q.Valid:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package viesapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientHistory(t *testing.T) {
+	valid := "true"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<result><vies><uid>test-uid</uid><countryCode>PL</countryCode><vatNumber>7272445205</vatNumber><valid>` + valid + `</valid><date>2024-01-15</date></vies><error><code>0</code></error></result>`))
+	}))
+	defer server.Close()
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	history := NewMemoryHistory()
+	c := NewVIESClient("test_id", "test_key", WithHistory(history), WithClock(func() time.Time { return now }))
+	c.SetUrl(server.URL)
+
+	c.GetVIESData("PL7272445205")
+	now = now.Add(24 * time.Hour)
+	valid = "false"
+	c.GetVIESData("PL7272445205")
+	c.GetVIESData("invalid")
+
+	ctx := context.Background()
+	recs, _ := history.Query(ctx, HistoryQuery{Number: "PL7272445205"})
+	if len(recs) != 2 || !recs[0].Data.Valid || recs[1].Data.Valid {
+		t.Fatalf("records = %+v, want valid then invalid", recs)
+	}
+
+	rec, err := HistoryAt(ctx, history, "PL7272445205", now.Add(-time.Hour))
+	if err != nil || rec == nil || !rec.Data.Valid {
+		t.Errorf("HistoryAt day before = %+v, %v; want valid", rec, err)
+	}
+	rec, _ = HistoryAt(ctx, history, "PL7272445205", now)
+	if rec == nil || rec.Data.Valid {
+		t.Errorf("HistoryAt now = %+v, want invalid", rec)
+	}
+	if rec, _ := HistoryAt(ctx, history, "PL7272445205", now.Add(-48*time.Hour)); rec != nil {
+		t.Errorf("HistoryAt before first check = %+v, want nil", rec)
+	}
+
+	invalid := false
+	recs, _ = history.Query(ctx, HistoryQuery{Valid: &invalid, From: now})
+	if len(recs) != 1 || !recs[0].Checked.Equal(now) {
+		t.Errorf("invalid since now = %+v, want one record", recs)
+	}
+}
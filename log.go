@@ -0,0 +1,67 @@
+package viesapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+// Log single request to the service
+func (c *VIESClient) logRequest(ctx context.Context, info RequestInfo) {
+
+	if c.logger == nil {
+		return
+	}
+
+	path := info.URL
+	if u, err := url.Parse(info.URL); err == nil {
+		path = u.Path
+	}
+	if i := strings.LastIndex(path, "/euvat/"); i >= 0 && !c.unmask {
+		path = path[:i+7] + maskNumber(path[i+7:])
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", "GET"),
+		slog.String("path", path),
+		slog.Duration("duration", info.Duration),
+		slog.Int("attempt", info.Attempt),
+	}
+	if info.Status != 0 {
+		attrs = append(attrs, slog.Int("status", info.Status), slog.Int("size", info.Size))
+	}
+
+	level := slog.LevelInfo
+	if info.Err != nil {
+		attrs = append(attrs, slog.String("error", c.errorText(info.Err)))
+	}
+	if info.Err != nil || (info.Status == 0 && info.Code != 0) {
+		level = slog.LevelError
+	} else if info.Code != 0 {
+		level = slog.LevelWarn
+	}
+	if info.Code != 0 {
+		attrs = append(attrs, slog.Int("code", info.Code))
+	}
+
+	c.logger.LogAttrs(ctx, level, "viesapi request", attrs...)
+}
+
+// Get error message without the request URL unless numbers are logged in full
+func (c *VIESClient) errorText(err error) string {
+	var uerr *url.Error
+	if c.unmask || !errors.As(err, &uerr) {
+		return err.Error()
+	}
+	return uerr.Op + ": " + uerr.Err.Error()
+}
+
+// Mask middle part of the number, keeping country code and last two characters
+func maskNumber(number string) string {
+	if len(number) <= 6 {
+		return strings.Repeat("*", len(number))
+	}
+	return number[:4] + strings.Repeat("*", len(number)-6) + number[len(number)-2:]
+}
@@ -0,0 +1,117 @@
+package viesapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLogRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<result><vies></vies><error><code>22</code><description>EU VAT ID is invalid</description></error></result>`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		opts []Option
+		path string
+	}{
+		{"masked", nil, "/get/vies/euvat/PL72******05"},
+		{"unmasked", []Option{WithUnmaskedLogs()}, "/get/vies/euvat/PL7272445205"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			opts := append([]Option{WithLogger(slog.New(slog.NewTextHandler(&buf, nil)))}, tt.opts...)
+			c := NewVIESClient("test_id", "secret_key", opts...)
+			c.SetUrl(server.URL)
+			c.GetVIESData("PL7272445205")
+
+			out := buf.String()
+			for _, want := range []string{"level=WARN", "path=" + tt.path, "status=200", "code=22", "attempt=1", "duration="} {
+				if !strings.Contains(out, want) {
+					t.Errorf("log %q missing %q", out, want)
+				}
+			}
+			for _, secret := range []string{"secret_key", "MAC id"} {
+				if strings.Contains(out, secret) {
+					t.Errorf("log %q leaks %q", out, secret)
+				}
+			}
+		})
+	}
+}
+
+func TestLogConnectError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	var buf bytes.Buffer
+	c := NewVIESClient("test_id", "test_key", WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+	c.SetUrl(url)
+
+	_, err := c.GetAccountStatus()
+	if err == nil || err.Code != CLI_CONNECT {
+		t.Fatalf("error = %v, want CLI_CONNECT", err)
+	}
+	if errors.Unwrap(err) == nil {
+		t.Error("transport error not kept on ViesError")
+	}
+	out := buf.String()
+	if !strings.Contains(out, "level=ERROR") || !strings.Contains(out, "error=") {
+		t.Errorf("log %q missing transport error", out)
+	}
+}
+
+func TestLogConnectErrorMasked(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	var buf bytes.Buffer
+	c := NewVIESClient("test_id", "test_key", WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+	c.SetUrl(url)
+
+	_, err := c.GetVIESData("PL7272445205")
+	if err == nil || err.Code != CLI_CONNECT {
+		t.Fatalf("error = %v, want CLI_CONNECT", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "error=\"Get: ") {
+		t.Errorf("log %q missing transport error", out)
+	}
+	if strings.Contains(out, "7272445205") {
+		t.Errorf("log %q leaks number", out)
+	}
+}
+
+func TestUnwrapCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := NewVIESClient("test_id", "test_key")
+	_, err := c.GetAccountStatusContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+}
+
+func TestMaskNumber(t *testing.T) {
+	tests := map[string]string{
+		"PL7272445205": "PL72******05",
+		"RO12":         "****",
+	}
+	for in, want := range tests {
+		if got := maskNumber(in); got != want {
+			t.Errorf("maskNumber(%s) = %s, want %s", in, got, want)
+		}
+	}
+}
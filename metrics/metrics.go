@@ -0,0 +1,251 @@
+// Package metrics collects VIES API client usage and account quota figures.
+//
+// A Collector counts requests, errors and latencies through client trace hooks
+// and keeps the latest account status refreshed with GetAccountStatus. It is
+// published with expvar and served in the Prometheus text exposition format
+// without depending on the Prometheus client library.
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/glaydus/viesapi"
+)
+
+// Upper bounds in seconds of the request duration histogram buckets
+var Buckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Collector gathers client usage and account quota figures
+type Collector struct {
+	mu       sync.Mutex
+	requests int64
+	errors   map[int]int64
+	count    int64
+	sum      float64
+	buckets  []int64
+	status   *viesapi.AccountStatus
+	updated  time.Time
+	failed   int64 // failed account status refreshes
+}
+
+// Point in time copy of collected figures
+type Snapshot struct {
+	Requests      int64                  `json:"requests"`
+	Errors        map[string]int64       `json:"errors"`
+	Latency       Latency                `json:"latency"`
+	Account       *viesapi.AccountStatus `json:"account,omitempty"`
+	Updated       *time.Time             `json:"updated,omitempty"`
+	EstimatedCost float64                `json:"estimated_cost"`
+	RefreshErrors int64                  `json:"refresh_errors"`
+}
+
+// Request duration histogram
+type Latency struct {
+	Count   int64            `json:"count"`
+	Sum     float64          `json:"sum"`
+	Buckets map[string]int64 `json:"buckets"` // cumulative counts keyed by upper bound
+}
+
+// Create new Collector
+func New() *Collector {
+	return &Collector{
+		errors:  make(map[int]int64),
+		buckets: make([]int64, len(Buckets)),
+	}
+}
+
+// Get trace hooks feeding the collector, to be passed to viesapi.WithTrace
+func (c *Collector) Trace() *viesapi.ClientTrace {
+	return &viesapi.ClientTrace{
+		RequestDone: c.observe,
+	}
+}
+
+// Record single request
+func (c *Collector) observe(info viesapi.RequestInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.requests++
+	if info.Code != 0 {
+		c.errors[info.Code]++
+	}
+
+	d := info.Duration.Seconds()
+	c.count++
+	c.sum += d
+	for i, b := range Buckets {
+		if d <= b {
+			c.buckets[i]++
+		}
+	}
+}
+
+// Refresh account status with specified client, failures are counted and
+// keep the previous status
+func (c *Collector) Refresh(ctx context.Context, client *viesapi.VIESClient) *viesapi.ViesError {
+	status, e := client.GetAccountStatusContext(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e != nil {
+		c.failed++
+		return e
+	}
+	c.status = status
+	c.updated = time.Now()
+	return nil
+}
+
+// Refresh account status every interval until the context is done, failed
+// refreshes are reported by the refresh error counter
+func (c *Collector) Run(ctx context.Context, client *viesapi.VIESClient, interval time.Duration) error {
+	if interval <= 0 {
+		return viesapi.ErrInterval
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		c.Refresh(ctx, client)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Get copy of collected figures
+func (c *Collector) Snapshot() Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := Snapshot{
+		Requests:      c.requests,
+		Errors:        make(map[string]int64, len(c.errors)),
+		RefreshErrors: c.failed,
+		Latency: Latency{
+			Count:   c.count,
+			Sum:     c.sum,
+			Buckets: make(map[string]int64, len(Buckets)),
+		},
+	}
+	for code, n := range c.errors {
+		s.Errors[strconv.Itoa(code)] = n
+	}
+	for i, b := range Buckets {
+		s.Latency.Buckets[formatFloat(b)] = c.buckets[i]
+	}
+	if c.status != nil {
+		status := *c.status
+		updated := c.updated
+		s.Account = &status
+		s.Updated = &updated
+		s.EstimatedCost = EstimatedCost(&status)
+	}
+	return s
+}
+
+// Estimate cost of the current billing period as the subscription price plus
+// the item price of every VIES data request made so far
+func EstimatedCost(s *viesapi.AccountStatus) float64 {
+	return s.SubscriptionPrice + s.ItemPrice*float64(s.VIESDataCount)
+}
+
+// Publish collected figures as expvar variable with specified name
+func (c *Collector) Publish(name string) {
+	expvar.Publish(name, c)
+}
+
+// Return collected figures as JSON, making Collector an expvar.Var
+func (c *Collector) String() string {
+	b, _ := json.Marshal(c.Snapshot())
+	return string(b)
+}
+
+// Serve collected figures in the Prometheus text exposition format
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.WritePrometheus(w)
+}
+
+// Write collected figures in the Prometheus text exposition format
+func (c *Collector) WritePrometheus(w io.Writer) {
+	s := c.Snapshot()
+
+	metric(w, "viesapi_requests_total", "counter", "Number of requests sent to the VIES API service.")
+	fmt.Fprintf(w, "viesapi_requests_total %d\n", s.Requests)
+
+	metric(w, "viesapi_errors_total", "counter", "Number of requests failed with an error code.")
+	codes := make([]string, 0, len(s.Errors))
+	for code := range s.Errors {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		a, _ := strconv.Atoi(codes[i])
+		b, _ := strconv.Atoi(codes[j])
+		return a < b
+	})
+	for _, code := range codes {
+		fmt.Fprintf(w, "viesapi_errors_total{code=%q} %d\n", code, s.Errors[code])
+	}
+
+	metric(w, "viesapi_request_duration_seconds", "histogram", "Duration of requests sent to the VIES API service.")
+	for _, b := range Buckets {
+		le := formatFloat(b)
+		fmt.Fprintf(w, "viesapi_request_duration_seconds_bucket{le=%q} %d\n", le, s.Latency.Buckets[le])
+	}
+	fmt.Fprintf(w, "viesapi_request_duration_seconds_bucket{le=\"+Inf\"} %d\n", s.Latency.Count)
+	fmt.Fprintf(w, "viesapi_request_duration_seconds_sum %s\n", formatFloat(s.Latency.Sum))
+	fmt.Fprintf(w, "viesapi_request_duration_seconds_count %d\n", s.Latency.Count)
+
+	metric(w, "viesapi_account_refresh_errors_total", "counter", "Number of failed account status refreshes.")
+	fmt.Fprintf(w, "viesapi_account_refresh_errors_total %d\n", s.RefreshErrors)
+
+	if s.Account == nil {
+		return
+	}
+	a := s.Account
+
+	gauges := []gauge{
+		{"viesapi_account_limit", "Request limit of the billing plan.", float64(a.Limit)},
+		{"viesapi_account_vies_data_requests", "VIES data requests made in the current period.", float64(a.VIESDataCount)},
+		{"viesapi_account_requests", "All requests made in the current period.", float64(a.TotalCount)},
+		{"viesapi_account_item_price", "Price of a single VIES data request.", a.ItemPrice},
+		{"viesapi_account_subscription_price", "Subscription price of the billing plan.", a.SubscriptionPrice},
+		{"viesapi_estimated_cost", "Estimated cost of the current billing period.", s.EstimatedCost},
+		{"viesapi_account_updated_timestamp_seconds", "Time of the last account status refresh.", float64(s.Updated.Unix())},
+	}
+	if a.ValidTo != nil {
+		gauges = append(gauges, gauge{"viesapi_account_valid_to_timestamp_seconds", "Expiry time of the account.", float64(a.ValidTo.Unix())})
+	}
+	for _, g := range gauges {
+		metric(w, g.name, "gauge", g.help)
+		fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.value))
+	}
+}
+
+type gauge struct {
+	name  string
+	help  string
+	value float64
+}
+
+// Write metric family header
+func metric(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
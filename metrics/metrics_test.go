@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glaydus/viesapi"
+)
+
+func newTestClient(t *testing.T, c *Collector) *viesapi.VIESClient {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/check/account/status"):
+			w.Write([]byte(`<result><account><uid>uid</uid><validTo>2030-01-01T00:00:00+00:00</validTo><billingPlan><subscriptionPrice>10</subscriptionPrice><itemPrice>0.5</itemPrice><limit>1000</limit></billingPlan><requests><viesData>20</viesData><total>30</total></requests></account><error><code>0</code></error></result>`))
+		case strings.HasSuffix(r.URL.Path, "/DE123456789"):
+			w.Write([]byte(`<result><error><code>23</code><description>VIES sync error</description></error></result>`))
+		default:
+			w.Write([]byte(`<result><vies><uid>uid</uid><countryCode>PL</countryCode><valid>true</valid><vatNumber>7272445205</vatNumber><date>2024-01-15</date></vies><error><code>0</code></error></result>`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client := viesapi.NewVIESClient("test_id", "test_key", viesapi.WithTrace(c.Trace()))
+	client.SetUrl(server.URL)
+	return client
+}
+
+func TestCollector(t *testing.T) {
+	c := New()
+	client := newTestClient(t, c)
+
+	client.GetVIESData("PL7272445205")
+	client.GetVIESData("DE123456789")
+	if e := c.Refresh(context.Background(), client); e != nil {
+		t.Fatalf("Refresh returned error: %v", e)
+	}
+
+	s := c.Snapshot()
+	if s.Requests != 3 {
+		t.Errorf("Requests = %d, want 3", s.Requests)
+	}
+	if s.Errors["23"] != 1 || len(s.Errors) != 1 {
+		t.Errorf("Errors = %v, want one VIES_SYNC", s.Errors)
+	}
+	if s.Latency.Count != 3 || s.Latency.Buckets["10"] != 3 {
+		t.Errorf("Latency = %+v, want 3 samples", s.Latency)
+	}
+	if s.Account == nil || s.Account.Limit != 1000 {
+		t.Fatalf("Account = %v, want refreshed status", s.Account)
+	}
+	if s.EstimatedCost != 20 {
+		t.Errorf("EstimatedCost = %v, want 20", s.EstimatedCost)
+	}
+
+	var decoded Snapshot
+	if err := json.Unmarshal([]byte(c.String()), &decoded); err != nil {
+		t.Errorf("String() returned invalid JSON: %v", err)
+	}
+}
+
+func TestCollectorPrometheus(t *testing.T) {
+	c := New()
+	client := newTestClient(t, c)
+	client.GetVIESData("DE123456789")
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	out := rec.Body.String()
+	for _, want := range []string{
+		"viesapi_requests_total 1\n",
+		"viesapi_errors_total{code=\"23\"} 1\n",
+		"viesapi_request_duration_seconds_bucket{le=\"+Inf\"} 1\n",
+		"viesapi_request_duration_seconds_count 1\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "viesapi_account_limit") {
+		t.Error("account gauges written before first refresh")
+	}
+
+	c.Refresh(context.Background(), client)
+	rec = httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	out = rec.Body.String()
+	for _, want := range []string{
+		"# TYPE viesapi_account_limit gauge\nviesapi_account_limit 1000\n",
+		"viesapi_estimated_cost 20\n",
+		"viesapi_account_valid_to_timestamp_seconds 1.893456e+09\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestCollectorRefreshErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<result><error><code>102</code><description>Auth error</description></error></result>`))
+	}))
+	defer server.Close()
+	client := viesapi.NewVIESClient("test_id", "test_key")
+	client.SetUrl(server.URL)
+
+	c := New()
+	if err := c.Run(context.Background(), client, 0); !errors.Is(err, viesapi.ErrInterval) {
+		t.Errorf("Run with zero interval = %v, want ErrInterval", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Run(ctx, client, time.Hour)
+	if s := c.Snapshot(); s.RefreshErrors != 1 || s.Account != nil {
+		t.Errorf("snapshot = %+v, want one refresh error", s)
+	}
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), "viesapi_account_refresh_errors_total 1\n") {
+		t.Errorf("output missing refresh errors:\n%s", rec.Body.String())
+	}
+}
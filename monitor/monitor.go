@@ -0,0 +1,228 @@
+// Package monitor re-verifies registered counterparties on a schedule and
+// reports changes of their VIES data.
+package monitor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/glaydus/viesapi"
+)
+
+var (
+	// Number is not a valid EU VAT number
+	ErrInvalidNumber = errors.New("monitor: invalid EU VAT number")
+	// Number is not registered for monitoring
+	ErrNotMonitored = errors.New("monitor: number is not monitored")
+)
+
+// Client looks up VIES data, satisfied by any viesapi.Verifier
+type Client = viesapi.Verifier
+
+// Kind of detected change
+type ChangeKind string
+
+const (
+	BecameInvalid      ChangeKind = "became_invalid"
+	BecameValid        ChangeKind = "became_valid"
+	NameChanged        ChangeKind = "name_changed"
+	AddressChanged     ChangeKind = "address_changed"
+	CompanyTypeChanged ChangeKind = "company_type_changed"
+)
+
+// Single change of monitored VIES data
+type Event struct {
+	Kind     ChangeKind        `json:"kind"`
+	Number   string            `json:"number"`
+	Time     time.Time         `json:"time"`
+	Previous *viesapi.VIESData `json:"previous"`
+	Current  *viesapi.VIESData `json:"current"`
+}
+
+// Option configures Monitor
+type Option func(*Monitor)
+
+// Call specified function for every detected change
+func OnChange(fn func(Event)) Option {
+	return func(m *Monitor) {
+		m.onChange = fn
+	}
+}
+
+// Call specified function when a lookup fails
+func OnError(fn func(number string, e *viesapi.ViesError)) Option {
+	return func(m *Monitor) {
+		m.onError = fn
+	}
+}
+
+// Monitor re-checks registered EU VAT numbers and detects changes
+type Monitor struct {
+	client   Client
+	store    Store
+	interval time.Duration
+	onChange func(Event)
+	onError  func(string, *viesapi.ViesError)
+	mu       sync.Mutex
+}
+
+// Create new Monitor re-checking numbers kept in store every interval
+func New(client Client, store Store, interval time.Duration, opts ...Option) *Monitor {
+	m := &Monitor{
+		client:   client,
+		store:    store,
+		interval: interval,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Register number for monitoring, its first check sets the baseline
+func (m *Monitor) Add(number string) error {
+	number, ok := viesapi.NormalizeEUVAT(number)
+	if !ok {
+		return ErrInvalidNumber
+	}
+	if _, ok, err := m.store.Get(number); ok || err != nil {
+		return err
+	}
+	return m.store.Put(Entry{Number: number})
+}
+
+// Unregister number from monitoring
+func (m *Monitor) Remove(number string) error {
+	if n, ok := viesapi.NormalizeEUVAT(number); ok {
+		number = n
+	}
+	return m.store.Delete(number)
+}
+
+// Re-check all monitored numbers every interval until the context is done
+func (m *Monitor) Run(ctx context.Context) error {
+	if m.interval <= 0 {
+		return viesapi.ErrInterval
+	}
+	t := time.NewTicker(m.interval)
+	defer t.Stop()
+
+	for {
+		if err := m.Check(ctx); err != nil && ctx.Err() == nil {
+			return err
+		}
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Re-check all monitored numbers once
+func (m *Monitor) Check(ctx context.Context) error {
+	entries, err := m.store.List()
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// lookup failures are reported through OnError and retried on the next run
+		var ve *viesapi.ViesError
+		if _, err := m.CheckNumber(ctx, entry.Number); err != nil && !errors.As(err, &ve) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Re-check single monitored number and return detected changes. Lookup
+// failures are returned as *viesapi.ViesError.
+func (m *Monitor) CheckNumber(ctx context.Context, number string) ([]Event, error) {
+	number, events, err := m.check(ctx, number)
+
+	// callbacks run outside the lock so that slow handlers do not stall other checks
+	var e *viesapi.ViesError
+	if errors.As(err, &e) && m.onError != nil {
+		m.onError(number, e)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if m.onChange != nil {
+		for _, ev := range events {
+			m.onChange(ev)
+		}
+	}
+	return events, nil
+}
+
+// Look up the number, store the result and return normalized number with detected changes
+func (m *Monitor) check(ctx context.Context, number string) (string, []Event, error) {
+
+	// serialize checks so that concurrent runs do not report the same change twice
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if n, ok := viesapi.NormalizeEUVAT(number); ok {
+		number = n
+	}
+	prev, ok, err := m.store.Get(number)
+	if err != nil {
+		return number, nil, err
+	}
+	if !ok {
+		return number, nil, ErrNotMonitored
+	}
+
+	data, e := m.client.GetVIESDataContext(ctx, number)
+	if e != nil {
+		return number, nil, e
+	}
+
+	now := time.Now()
+	events := diff(number, now, prev.Data, data)
+
+	// the last known result of a failed lookup does not make the entry fresh
+	checked := now
+	if data.Degraded {
+		checked = prev.Checked
+	}
+	if err := m.store.Put(Entry{Number: number, Data: data, Checked: checked}); err != nil {
+		return number, nil, err
+	}
+	return number, events, nil
+}
+
+// Compare previous and current VIES data
+func diff(number string, t time.Time, prev, cur *viesapi.VIESData) []Event {
+	if prev == nil {
+		return nil
+	}
+
+	var events []Event
+	add := func(kind ChangeKind) {
+		events = append(events, Event{Kind: kind, Number: number, Time: t, Previous: prev, Current: cur})
+	}
+
+	if prev.Valid && !cur.Valid {
+		add(BecameInvalid)
+	}
+	if !prev.Valid && cur.Valid {
+		add(BecameValid)
+	}
+	if prev.TraderName != cur.TraderName {
+		add(NameChanged)
+	}
+	if prev.TraderAddress != cur.TraderAddress {
+		add(AddressChanged)
+	}
+	if prev.TraderCompanyType != cur.TraderCompanyType {
+		add(CompanyTypeChanged)
+	}
+	return events
+}
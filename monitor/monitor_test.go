@@ -0,0 +1,190 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glaydus/viesapi"
+)
+
+// Client returning preset data per number
+type fakeClient struct {
+	mu   sync.Mutex
+	data map[string]viesapi.VIESData
+}
+
+func (f *fakeClient) set(number string, data viesapi.VIESData) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.data[number] = data
+}
+
+func (f *fakeClient) GetVIESDataContext(ctx context.Context, euvat string) (*viesapi.VIESData, *viesapi.ViesError) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d, ok := f.data[euvat]
+	if !ok {
+		return nil, &viesapi.ViesError{Code: viesapi.VIES_SYNC, Description: "VIES sync error"}
+	}
+	return &d, nil
+}
+
+func TestMonitorChanges(t *testing.T) {
+	client := &fakeClient{data: make(map[string]viesapi.VIESData)}
+	client.set("PL7272445205", viesapi.VIESData{Valid: true, TraderName: "Old", TraderAddress: "Street 1", TraderCompanyType: "SA"})
+
+	var events []Event
+	m := New(client, NewMemoryStore(), time.Hour, OnChange(func(e Event) { events = append(events, e) }))
+
+	if err := m.Add("PL 727-244-52-05"); err != nil {
+		t.Fatalf("Add returned error: %v", err)
+	}
+	if err := m.Add("PL7272445206"); !errors.Is(err, ErrInvalidNumber) {
+		t.Errorf("Add invalid number = %v, want ErrInvalidNumber", err)
+	}
+
+	// first check sets the baseline
+	if err := m.Check(context.Background()); err != nil {
+		t.Fatalf("Check returned error: %v", err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("baseline check emitted %v", events)
+	}
+
+	client.set("PL7272445205", viesapi.VIESData{Valid: false, TraderName: "New", TraderAddress: "Street 2", TraderCompanyType: "SA"})
+	m.Check(context.Background())
+
+	var kinds []ChangeKind
+	for _, e := range events {
+		kinds = append(kinds, e.Kind)
+		if e.Number != "PL7272445205" || e.Previous.TraderName != "Old" || e.Current.TraderName != "New" {
+			t.Errorf("event = %+v", e)
+		}
+	}
+	want := []ChangeKind{BecameInvalid, NameChanged, AddressChanged}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Errorf("kinds = %v, want %v", kinds, want)
+	}
+
+	events = nil
+	client.set("PL7272445205", viesapi.VIESData{Valid: true, TraderName: "New", TraderAddress: "Street 2", TraderCompanyType: "SP"})
+	got, err := m.CheckNumber(context.Background(), "PL7272445205")
+	if err != nil {
+		t.Fatalf("CheckNumber returned error: %v", err)
+	}
+	if len(got) != 2 || got[0].Kind != BecameValid || got[1].Kind != CompanyTypeChanged {
+		t.Errorf("CheckNumber events = %+v, want became valid and company type changed", got)
+	}
+
+	if _, err := m.CheckNumber(context.Background(), "DE123456789"); !errors.Is(err, ErrNotMonitored) {
+		t.Errorf("CheckNumber unmonitored = %v, want ErrNotMonitored", err)
+	}
+}
+
+func TestMonitorCallbackUnlocked(t *testing.T) {
+	client := &fakeClient{data: make(map[string]viesapi.VIESData)}
+	client.set("PL7272445205", viesapi.VIESData{Valid: true})
+
+	// callback checking another number must not deadlock on the monitor lock
+	var m *Monitor
+	checked := make(chan error, 1)
+	m = New(client, NewMemoryStore(), time.Hour, OnChange(func(e Event) {
+		_, err := m.CheckNumber(context.Background(), "PL7272445205")
+		checked <- err
+	}))
+	m.Add("PL7272445205")
+	m.Check(context.Background())
+
+	client.set("PL7272445205", viesapi.VIESData{Valid: false})
+	done := make(chan struct{})
+	go func() {
+		m.Check(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Check blocked by OnChange callback")
+	}
+	if err := <-checked; err != nil {
+		t.Errorf("CheckNumber in callback = %v", err)
+	}
+}
+
+func TestMonitorDegraded(t *testing.T) {
+	client := &fakeClient{data: make(map[string]viesapi.VIESData)}
+	client.set("PL7272445205", viesapi.VIESData{Valid: true})
+	store := NewMemoryStore()
+	m := New(client, store, time.Hour)
+	m.Add("PL7272445205")
+	m.Check(context.Background())
+	first, _, _ := store.Get("PL7272445205")
+
+	// the last known result served on failure does not refresh the check time
+	client.set("PL7272445205", viesapi.VIESData{Valid: true, Stale: true, Degraded: true})
+	m.Check(context.Background())
+	if e, _, _ := store.Get("PL7272445205"); !e.Checked.Equal(first.Checked) || !e.Data.Degraded {
+		t.Errorf("entry = %+v, want degraded data checked at %v", e, first.Checked)
+	}
+}
+
+func TestMonitorLookupError(t *testing.T) {
+	client := &fakeClient{data: make(map[string]viesapi.VIESData)}
+	var failed []string
+	m := New(client, NewMemoryStore(), time.Hour, OnError(func(number string, e *viesapi.ViesError) {
+		failed = append(failed, number)
+	}))
+	m.Add("DE123456789")
+
+	if err := m.Check(context.Background()); err != nil {
+		t.Fatalf("Check returned error: %v", err)
+	}
+	if len(failed) != 1 || failed[0] != "DE123456789" {
+		t.Errorf("failed = %v, want DE123456789", failed)
+	}
+
+	_, err := m.CheckNumber(context.Background(), "DE123456789")
+	var ve *viesapi.ViesError
+	if !errors.As(err, &ve) || ve.Code != viesapi.VIES_SYNC {
+		t.Errorf("CheckNumber error = %v, want VIES_SYNC", err)
+	}
+}
+
+func TestMonitorInterval(t *testing.T) {
+	m := New(&fakeClient{}, NewMemoryStore(), 0)
+	if err := m.Run(context.Background()); !errors.Is(err, viesapi.ErrInterval) {
+		t.Errorf("Run = %v, want ErrInterval", err)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "monitor.json")
+
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore returned error: %v", err)
+	}
+	checked := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	s.Put(Entry{Number: "PL7272445205", Data: &viesapi.VIESData{TraderName: "Test"}, Checked: checked})
+	s.Put(Entry{Number: "DE123456789"})
+	s.Put(Entry{Number: "FR12345678901"})
+	s.Delete("FR12345678901")
+
+	s, err = NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore reopen returned error: %v", err)
+	}
+	entries, _ := s.List()
+	if len(entries) != 2 || entries[0].Number != "DE123456789" {
+		t.Fatalf("entries = %+v, want DE and PL", entries)
+	}
+	e, ok, _ := s.Get("PL7272445205")
+	if !ok || e.Data.TraderName != "Test" || !e.Checked.Equal(checked) {
+		t.Errorf("Get = %+v, %v", e, ok)
+	}
+}
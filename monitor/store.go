@@ -0,0 +1,166 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/glaydus/viesapi"
+)
+
+// State of a monitored number
+type Entry struct {
+	Number  string            `json:"number"`
+	Data    *viesapi.VIESData `json:"data,omitempty"` // nil until the first successful check
+	Checked time.Time         `json:"checked"`
+}
+
+// Store persists monitored numbers and their last known state
+type Store interface {
+	// List returns all entries ordered by number
+	List() ([]Entry, error)
+	// Get returns entry for specified number
+	Get(number string) (Entry, bool, error)
+	// Put adds or replaces entry
+	Put(entry Entry) error
+	// Delete removes entry for specified number
+	Delete(number string) error
+}
+
+// In-memory Store
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+// Create new empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]Entry)}
+}
+
+// List all entries ordered by number
+func (s *MemoryStore) List() ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sorted(s.entries), nil
+}
+
+// Get entry for specified number
+func (s *MemoryStore) Get(number string) (Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[number]
+	return e, ok, nil
+}
+
+// Add or replace entry
+func (s *MemoryStore) Put(entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[entry.Number] = entry
+	return nil
+}
+
+// Remove entry for specified number
+func (s *MemoryStore) Delete(number string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, number)
+	return nil
+}
+
+// Store keeping entries in a JSON file, rewritten atomically on every change
+type FileStore struct {
+	mem  *MemoryStore
+	path string
+	mu   sync.Mutex
+}
+
+// Open FileStore at specified path, loading existing entries if the file exists
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{mem: NewMemoryStore(), path: path}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		s.mem.entries[e.Number] = e
+	}
+	return s, nil
+}
+
+// List all entries ordered by number
+func (s *FileStore) List() ([]Entry, error) {
+	return s.mem.List()
+}
+
+// Get entry for specified number
+func (s *FileStore) Get(number string) (Entry, bool, error) {
+	return s.mem.Get(number)
+}
+
+// Add or replace entry and save the file
+func (s *FileStore) Put(entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mem.Put(entry)
+	return s.save()
+}
+
+// Remove entry for specified number and save the file
+func (s *FileStore) Delete(number string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mem.Delete(number)
+	return s.save()
+}
+
+// Write all entries to a temporary file and move it over the store file
+func (s *FileStore) save() error {
+	entries, _ := s.mem.List()
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.path)
+}
+
+// Get entries ordered by number
+func sorted(m map[string]Entry) []Entry {
+	entries := make([]Entry, 0, len(m))
+	for _, e := range m {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Number < entries[j].Number
+	})
+	return entries
+}
@@ -0,0 +1,291 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/glaydus/viesapi"
+)
+
+// Header carrying webhook payload signature
+const SignatureHeader = "X-VIESAPI-Signature"
+
+var (
+	// Signature header is missing or malformed
+	ErrSignature = errors.New("monitor: malformed webhook signature")
+	// Signature does not match the payload
+	ErrSignatureMismatch = errors.New("monitor: webhook signature mismatch")
+	// Signature timestamp is outside of the accepted window
+	ErrSignatureExpired = errors.New("monitor: webhook signature expired")
+	// Event was not queued because deliveries fall behind
+	ErrQueueFull = errors.New("monitor: webhook queue full")
+	// Event was still queued when the notifier stopped
+	ErrStopped = errors.New("monitor: notifier stopped")
+)
+
+var reSignatureParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// Webhook payload
+type Payload struct {
+	ID string `json:"id"`
+	Event
+}
+
+// Failed delivery written to the dead-letter log
+type DeadLetter struct {
+	URL      string    `json:"url"`
+	Payload  Payload   `json:"payload"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	Time     time.Time `json:"time"`
+}
+
+// NotifierOption configures Notifier
+type NotifierOption func(*Notifier)
+
+// Use specified HTTP client for deliveries
+func WithHTTPClient(client *http.Client) NotifierOption {
+	return func(n *Notifier) {
+		n.client = client
+	}
+}
+
+// Try each delivery up to attempts times, doubling the delay starting from backoff
+func WithRetry(attempts int, backoff time.Duration) NotifierOption {
+	return func(n *Notifier) {
+		n.attempts = attempts
+		n.backoff = backoff
+	}
+}
+
+// Write failed deliveries as JSON lines to specified writer. Without it
+// events that could not be delivered are lost and only counted by Dropped.
+func WithDeadLetter(w io.Writer) NotifierOption {
+	return func(n *Notifier) {
+		n.deadLetter = w
+	}
+}
+
+// Notifier posts signed change events to webhook URLs
+type Notifier struct {
+	urls       []string
+	secret     string
+	client     *http.Client
+	attempts   int
+	backoff    time.Duration
+	deadLetter io.Writer
+	queue      chan Event
+	dropped    atomic.Int64
+	mu         sync.Mutex
+}
+
+// Create new Notifier posting events signed with secret to specified URLs
+func NewNotifier(secret string, urls []string, opts ...NotifierOption) *Notifier {
+	n := &Notifier{
+		urls:     urls,
+		secret:   secret,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		attempts: 5,
+		backoff:  time.Second,
+		queue:    make(chan Event, 100),
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
+}
+
+// Queue event for delivery without blocking, suitable as Monitor OnChange
+// callback. Events that do not fit in the queue go to the dead-letter log.
+func (n *Notifier) Notify(e Event) {
+	select {
+	case n.queue <- e:
+		return
+	default:
+	}
+	n.drop(e, ErrQueueFull)
+}
+
+// Get number of events dropped without delivery because the queue was full
+// or the notifier stopped
+func (n *Notifier) Dropped() int64 {
+	return n.dropped.Load()
+}
+
+// Deliver queued events until the context is done, then move events still
+// queued to the dead-letter log
+func (n *Notifier) Run(ctx context.Context) {
+	for {
+		select {
+		case e := <-n.queue:
+			if ctx.Err() != nil {
+				n.drop(e, ErrStopped)
+				continue
+			}
+			n.Send(ctx, e)
+		case <-ctx.Done():
+			n.flush()
+			return
+		}
+	}
+}
+
+// Move queued events to the dead-letter log
+func (n *Notifier) flush() {
+	for {
+		select {
+		case e := <-n.queue:
+			n.drop(e, ErrStopped)
+		default:
+			return
+		}
+	}
+}
+
+// Count event dropped without delivery and write it to the dead-letter log
+func (n *Notifier) drop(e Event, err error) {
+	n.dropped.Add(1)
+	p := Payload{Event: e}
+	for _, url := range n.urls {
+		n.dead(DeadLetter{URL: url, Payload: p, Error: err.Error(), Time: time.Now()})
+	}
+}
+
+// Deliver event to all URLs, failed deliveries go to the dead-letter log
+func (n *Notifier) Send(ctx context.Context, e Event) error {
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+	p := Payload{ID: id, Event: e}
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, url := range n.urls {
+		attempts, err := n.deliver(ctx, url, body)
+		if err != nil {
+			n.dead(DeadLetter{URL: url, Payload: p, Attempts: attempts, Error: err.Error(), Time: time.Now()})
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Post body to url with retries and return number of attempts made
+func (n *Notifier) deliver(ctx context.Context, url string, body []byte) (int, error) {
+	delay := n.backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := n.post(ctx, url, body)
+		if err == nil {
+			return attempt, nil
+		}
+		if !retry || attempt >= n.attempts {
+			return attempt, err
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return attempt, ctx.Err()
+		}
+		delay *= 2
+	}
+}
+
+// Post signed body once, reporting whether a failure is worth retrying
+func (n *Notifier) post(ctx context.Context, url string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(n.secret, time.Now(), body))
+
+	res, err := n.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return false, nil
+	case res.StatusCode >= 500, res.StatusCode == http.StatusTooManyRequests, res.StatusCode == http.StatusRequestTimeout:
+		return true, fmt.Errorf("monitor: webhook responded %s", res.Status)
+	default:
+		return false, fmt.Errorf("monitor: webhook responded %s", res.Status)
+	}
+}
+
+// Append failed delivery to the dead-letter log
+func (n *Notifier) dead(d DeadLetter) {
+	if n.deadLetter == nil {
+		return
+	}
+	b, _ := json.Marshal(d)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.deadLetter.Write(append(b, '\n'))
+}
+
+// Create signature header content for body sent at specified time
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := t.Unix()
+	return fmt.Sprintf(`ts="%d", mac="%s"`, ts, viesapi.MAC(secret, strconv.FormatInt(ts, 10)+"\n"+string(body)))
+}
+
+// Verify signature header content of received body, accepting timestamps
+// within tolerance of the local clock
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	params := make(map[string]string)
+	for _, m := range reSignatureParam.FindAllStringSubmatch(header, -1) {
+		params[m[1]] = m[2]
+	}
+	ts, err := strconv.ParseInt(params["ts"], 10, 64)
+	if err != nil {
+		return ErrSignature
+	}
+	mac, err := base64.StdEncoding.DecodeString(params["mac"])
+	if err != nil {
+		return ErrSignature
+	}
+
+	expected, _ := base64.StdEncoding.DecodeString(viesapi.MAC(secret, params["ts"]+"\n"+string(body)))
+	if !hmac.Equal(mac, expected) {
+		return ErrSignatureMismatch
+	}
+	if d := time.Since(time.Unix(ts, 0)); d > tolerance || d < -tolerance {
+		return ErrSignatureExpired
+	}
+	return nil
+}
+
+// Get random payload identifier
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
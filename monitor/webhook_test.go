@@ -0,0 +1,179 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/glaydus/viesapi"
+)
+
+func testEvent() Event {
+	return Event{
+		Kind:     BecameInvalid,
+		Number:   "PL7272445205",
+		Time:     time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC),
+		Previous: &viesapi.VIESData{Valid: true},
+		Current:  &viesapi.VIESData{Valid: false},
+	}
+}
+
+func TestNotifierDelivery(t *testing.T) {
+	received := make(chan Payload, 1)
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// fail the first attempt to exercise retries
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify("secret", r.Header.Get(SignatureHeader), body, time.Minute); err != nil {
+			t.Errorf("Verify returned error: %v", err)
+		}
+		var p Payload
+		json.Unmarshal(body, &p)
+		received <- p
+	}))
+	defer server.Close()
+
+	n := NewNotifier("secret", []string{server.URL}, WithRetry(3, time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.Run(ctx)
+
+	n.Notify(testEvent())
+
+	select {
+	case p := <-received:
+		if p.ID == "" || p.Kind != BecameInvalid || p.Number != "PL7272445205" || p.Previous == nil || !p.Previous.Valid {
+			t.Errorf("payload = %+v", p)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("webhook not delivered")
+	}
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+}
+
+func TestNotifierDeadLetter(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer rejecting.Close()
+
+	var log bytes.Buffer
+	n := NewNotifier("secret", []string{server.URL, rejecting.URL}, WithRetry(3, time.Millisecond), WithDeadLetter(&log))
+
+	if err := n.Send(context.Background(), testEvent()); err == nil {
+		t.Fatal("Send should fail")
+	}
+	if attempts != 3 {
+		t.Errorf("attempts = %d, want 3", attempts)
+	}
+
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("dead letters = %d, want 2", len(lines))
+	}
+	var d DeadLetter
+	if err := json.Unmarshal([]byte(lines[0]), &d); err != nil {
+		t.Fatalf("invalid dead letter: %v", err)
+	}
+	if d.URL != server.URL || d.Attempts != 3 || d.Payload.Number != "PL7272445205" || d.Error == "" {
+		t.Errorf("dead letter = %+v", d)
+	}
+	json.Unmarshal([]byte(lines[1]), &d)
+	if d.URL != rejecting.URL || d.Attempts != 1 {
+		t.Errorf("client errors should not be retried, dead letter = %+v", d)
+	}
+}
+
+func TestNotifierQueueFull(t *testing.T) {
+	var log bytes.Buffer
+	n := NewNotifier("secret", []string{"http://example.invalid/hook"}, WithDeadLetter(&log))
+
+	// without Run nothing drains the queue, Notify must still return
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 101; i++ {
+			n.Notify(testEvent())
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Notify blocked on full queue")
+	}
+
+	if n.Dropped() != 1 {
+		t.Errorf("Dropped() = %d, want 1", n.Dropped())
+	}
+	var d DeadLetter
+	if err := json.Unmarshal(log.Bytes(), &d); err != nil {
+		t.Fatalf("invalid dead letter: %v", err)
+	}
+	if d.Error != ErrQueueFull.Error() || d.Payload.Number != "PL7272445205" {
+		t.Errorf("dead letter = %+v", d)
+	}
+}
+
+func TestNotifierStop(t *testing.T) {
+	var log bytes.Buffer
+	n := NewNotifier("secret", []string{"http://example.invalid/hook"}, WithDeadLetter(&log))
+	n.Notify(testEvent())
+	n.Notify(testEvent())
+
+	// events queued when the notifier stops are not lost
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n.Run(ctx)
+
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	if len(lines) != 2 || n.Dropped() != 2 {
+		t.Fatalf("dead letters = %d, dropped %d; want 2, 2", len(lines), n.Dropped())
+	}
+	var d DeadLetter
+	if err := json.Unmarshal([]byte(lines[0]), &d); err != nil || d.Error != ErrStopped.Error() {
+		t.Errorf("dead letter = %+v, %v; want ErrStopped", d, err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"kind":"name_changed"}`)
+	header := Sign("secret", time.Now(), body)
+
+	if err := Verify("secret", header, body, time.Minute); err != nil {
+		t.Errorf("Verify returned error: %v", err)
+	}
+	if err := Verify("other", header, body, time.Minute); !errors.Is(err, ErrSignatureMismatch) {
+		t.Errorf("Verify with other secret = %v, want ErrSignatureMismatch", err)
+	}
+	if err := Verify("secret", header, []byte(`{}`), time.Minute); !errors.Is(err, ErrSignatureMismatch) {
+		t.Errorf("Verify with other body = %v, want ErrSignatureMismatch", err)
+	}
+	old := Sign("secret", time.Now().Add(-time.Hour), body)
+	if err := Verify("secret", old, body, time.Minute); !errors.Is(err, ErrSignatureExpired) {
+		t.Errorf("Verify old signature = %v, want ErrSignatureExpired", err)
+	}
+	if err := Verify("secret", "", body, time.Minute); !errors.Is(err, ErrSignature) {
+		t.Errorf("Verify empty header = %v, want ErrSignature", err)
+	}
+}
@@ -0,0 +1,233 @@
+// Package otelviesapi provides OpenTelemetry tracing and metrics for viesapi clients.
+//
+// Client wraps a viesapi.VIESClient and runs every call in a span, recording
+// latency, error and validity metrics. NewTransport propagates the trace
+// context on outgoing requests to the service:
+//
+//	client := viesapi.NewVIESClient(id, key, viesapi.WithHTTPClient(&http.Client{
+//		Transport: otelviesapi.NewTransport(nil),
+//	}))
+//	traced, err := otelviesapi.New(client)
+package otelviesapi
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/glaydus/viesapi"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/glaydus/viesapi/otelviesapi"
+
+// Attribute keys set on spans and metrics
+const (
+	AttrOperation   = attribute.Key("viesapi.operation")
+	AttrCountryCode = attribute.Key("viesapi.country_code")
+	AttrValid       = attribute.Key("viesapi.valid")
+	AttrErrorCode   = attribute.Key("viesapi.error.code")
+	AttrCacheHit    = attribute.Key("viesapi.cache_hit")
+	AttrAttempt     = attribute.Key("viesapi.attempt")
+	AttrHTTPStatus  = attribute.Key("http.response.status_code")
+)
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	propagators    propagation.TextMapPropagator
+}
+
+// Option configures instrumentation
+type Option func(*config)
+
+// Use specified tracer provider instead of the global one
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tp
+	}
+}
+
+// Use specified meter provider instead of the global one
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = mp
+	}
+}
+
+// Use specified propagators instead of the global ones
+func WithPropagators(p propagation.TextMapPropagator) Option {
+	return func(c *config) {
+		c.propagators = p
+	}
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+		propagators:    otel.GetTextMapPropagator(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Client is an instrumented VIESClient
+type Client struct {
+	client   *viesapi.VIESClient
+	tracer   trace.Tracer
+	duration metric.Float64Histogram
+	errors   metric.Int64Counter
+	lookups  metric.Int64Counter
+}
+
+// Create new instrumented client wrapping specified VIESClient
+func New(client *viesapi.VIESClient, opts ...Option) (*Client, error) {
+	cfg := newConfig(opts)
+	meter := cfg.meterProvider.Meter(instrumentationName)
+
+	duration, err := meter.Float64Histogram("viesapi.client.duration",
+		metric.WithDescription("Duration of VIES API client calls"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	errors, err := meter.Int64Counter("viesapi.client.errors",
+		metric.WithDescription("Number of failed VIES API client calls by error code"),
+		metric.WithUnit("{call}"))
+	if err != nil {
+		return nil, err
+	}
+	lookups, err := meter.Int64Counter("viesapi.client.lookups",
+		metric.WithDescription("Number of successful VIES data lookups by country and validity"),
+		metric.WithUnit("{lookup}"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		client:   client,
+		tracer:   cfg.tracerProvider.Tracer(instrumentationName),
+		duration: duration,
+		errors:   errors,
+		lookups:  lookups,
+	}, nil
+}
+
+// Get VIES data for specified number within a span
+func (c *Client) GetVIESData(ctx context.Context, euvat string) (*viesapi.VIESData, *viesapi.ViesError) {
+	ctx, span := c.start(ctx, "GetVIESData")
+	defer span.End()
+
+	start := time.Now()
+	data, e := c.client.GetVIESDataContext(ctx, euvat)
+
+	country := countryCode(euvat)
+	if data != nil {
+		country = data.CountryCode
+	}
+	attrs := []attribute.KeyValue{AttrOperation.String("GetVIESData"), AttrCountryCode.String(country)}
+	span.SetAttributes(AttrCountryCode.String(country))
+
+	c.end(ctx, span, start, attrs, e)
+	if data != nil {
+		span.SetAttributes(AttrValid.Bool(data.Valid))
+		c.lookups.Add(ctx, 1, metric.WithAttributes(AttrCountryCode.String(country), AttrValid.Bool(data.Valid)))
+	}
+	return data, e
+}
+
+// Get account status within a span
+func (c *Client) GetAccountStatus(ctx context.Context) (*viesapi.AccountStatus, *viesapi.ViesError) {
+	ctx, span := c.start(ctx, "GetAccountStatus")
+	defer span.End()
+
+	start := time.Now()
+	status, e := c.client.GetAccountStatusContext(ctx)
+
+	c.end(ctx, span, start, []attribute.KeyValue{AttrOperation.String("GetAccountStatus")}, e)
+	return status, e
+}
+
+// Start span for specified operation and hook client trace events into it
+func (c *Client) start(ctx context.Context, op string) (context.Context, trace.Span) {
+	ctx, span := c.tracer.Start(ctx, "viesapi."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(AttrOperation.String(op)))
+
+	outer := viesapi.ContextClientTrace(ctx)
+	ctx = viesapi.WithClientTrace(ctx, &viesapi.ClientTrace{
+		CacheLookup: func(number string, hit bool) {
+			span.SetAttributes(AttrCacheHit.Bool(hit))
+			if outer != nil && outer.CacheLookup != nil {
+				outer.CacheLookup(number, hit)
+			}
+		},
+		RequestDone: func(info viesapi.RequestInfo) {
+			attrs := []attribute.KeyValue{AttrAttempt.Int(info.Attempt)}
+			if info.Status != 0 {
+				attrs = append(attrs, AttrHTTPStatus.Int(info.Status))
+			}
+			if info.Code != 0 {
+				attrs = append(attrs, AttrErrorCode.Int(info.Code))
+			}
+			span.AddEvent("request", trace.WithAttributes(attrs...))
+			if outer != nil && outer.RequestDone != nil {
+				outer.RequestDone(info)
+			}
+		},
+	})
+	return ctx, span
+}
+
+// Record outcome of the call on span and metrics
+func (c *Client) end(ctx context.Context, span trace.Span, start time.Time, attrs []attribute.KeyValue, e *viesapi.ViesError) {
+	if e != nil {
+		span.SetAttributes(AttrErrorCode.Int(e.Code))
+		span.SetStatus(codes.Error, e.Description)
+		attrs = append(attrs, AttrErrorCode.Int(e.Code))
+		c.errors.Add(ctx, 1, metric.WithAttributes(attrs...))
+	}
+	c.duration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(attrs...))
+}
+
+// Get country code prefix of the number as entered
+func countryCode(euvat string) string {
+	euvat = strings.TrimSpace(euvat)
+	if len(euvat) < 2 {
+		return ""
+	}
+	return strings.ToUpper(euvat[:2])
+}
+
+type transport struct {
+	base        http.RoundTripper
+	propagators propagation.TextMapPropagator
+}
+
+// Create HTTP transport injecting trace context of the request into its headers.
+// Nil base defaults to http.DefaultTransport.
+func NewTransport(base http.RoundTripper, opts ...Option) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{
+		base:        base,
+		propagators: newConfig(opts).propagators,
+	}
+}
+
+// Send request with injected trace context
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	t.propagators.Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,160 @@
+package otelviesapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/glaydus/viesapi"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type testEnv struct {
+	client   *Client
+	spans    *tracetest.InMemoryExporter
+	reader   *sdkmetric.ManualReader
+	provider *sdktrace.TracerProvider
+	headers  chan http.Header
+}
+
+func newTestEnv(t *testing.T, response string) *testEnv {
+	t.Helper()
+
+	env := &testEnv{
+		spans:   tracetest.NewInMemoryExporter(),
+		reader:  sdkmetric.NewManualReader(),
+		headers: make(chan http.Header, 10),
+	}
+	env.provider = sdktrace.NewTracerProvider(sdktrace.WithSyncer(env.spans))
+	prop := propagation.TraceContext{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		env.headers <- r.Header
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+
+	vc := viesapi.NewVIESClient("test_id", "test_key",
+		viesapi.WithCache(viesapi.NewMemoryCache(0), time.Minute),
+		viesapi.WithHTTPClient(&http.Client{Transport: NewTransport(nil, WithPropagators(prop))}))
+	vc.SetUrl(server.URL)
+
+	c, err := New(vc,
+		WithTracerProvider(env.provider),
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(env.reader))),
+		WithPropagators(prop))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	env.client = c
+	return env
+}
+
+func spanAttr(span tracetest.SpanStub, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func (env *testEnv) metric(t *testing.T, name string) metricdata.Metrics {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := env.reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect returned error: %v", err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m
+			}
+		}
+	}
+	t.Fatalf("metric %s not recorded", name)
+	return metricdata.Metrics{}
+}
+
+func TestGetVIESDataSpan(t *testing.T) {
+	env := newTestEnv(t, `<result><vies><uid>test-uid</uid><countryCode>PL</countryCode><valid>true</valid><vatNumber>7272445205</vatNumber><date>2024-01-15</date></vies><error><code>0</code></error></result>`)
+
+	for i := 0; i < 2; i++ {
+		if _, err := env.client.GetVIESData(context.Background(), "PL7272445205"); err != nil {
+			t.Fatalf("GetVIESData returned error: %v", err)
+		}
+	}
+
+	h := <-env.headers
+	if h.Get("Traceparent") == "" {
+		t.Error("trace context not propagated on outgoing request")
+	}
+
+	spans := env.spans.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("spans = %d, want 2", len(spans))
+	}
+	for i, want := range []bool{false, true} {
+		span := spans[i]
+		if span.Name != "viesapi.GetVIESData" {
+			t.Errorf("span name = %s", span.Name)
+		}
+		if v, _ := spanAttr(span, AttrCountryCode); v.AsString() != "PL" {
+			t.Errorf("country code = %v, want PL", v.AsString())
+		}
+		if v, ok := spanAttr(span, AttrCacheHit); !ok || v.AsBool() != want {
+			t.Errorf("span %d cache hit = %v, want %v", i, v.AsBool(), want)
+		}
+	}
+	if len(spans[0].Events) != 1 || len(spans[1].Events) != 0 {
+		t.Errorf("request events = %d/%d, want 1/0", len(spans[0].Events), len(spans[1].Events))
+	}
+	if tp := h.Get("Traceparent"); tp[3:35] != spans[0].SpanContext.TraceID().String() {
+		t.Errorf("traceparent %s does not carry span trace id", tp)
+	}
+
+	lookups := env.metric(t, "viesapi.client.lookups").Data.(metricdata.Sum[int64])
+	if len(lookups.DataPoints) != 1 || lookups.DataPoints[0].Value != 2 {
+		t.Errorf("lookups = %+v, want 2 valid PL lookups", lookups.DataPoints)
+	}
+}
+
+func TestGetVIESDataErrorSpan(t *testing.T) {
+	env := newTestEnv(t, `<result><error><code>23</code><description>VIES sync error</description></error></result>`)
+
+	if _, err := env.client.GetVIESData(context.Background(), "DE123456789"); err == nil {
+		t.Fatal("GetVIESData should return error")
+	}
+
+	span := env.spans.GetSpans()[0]
+	if span.Status.Code != codes.Error {
+		t.Errorf("span status = %v, want error", span.Status.Code)
+	}
+	if v, _ := spanAttr(span, AttrErrorCode); v.AsInt64() != viesapi.VIES_SYNC {
+		t.Errorf("error code = %d, want %d", v.AsInt64(), viesapi.VIES_SYNC)
+	}
+	if v, _ := spanAttr(span, AttrCountryCode); v.AsString() != "DE" {
+		t.Errorf("country code = %s, want DE", v.AsString())
+	}
+
+	errs := env.metric(t, "viesapi.client.errors").Data.(metricdata.Sum[int64])
+	if len(errs.DataPoints) != 1 {
+		t.Fatalf("error data points = %d, want 1", len(errs.DataPoints))
+	}
+	if v, _ := errs.DataPoints[0].Attributes.Value(AttrErrorCode); v.AsInt64() != viesapi.VIES_SYNC {
+		t.Errorf("error metric code = %d, want %d", v.AsInt64(), viesapi.VIES_SYNC)
+	}
+
+	hist := env.metric(t, "viesapi.client.duration").Data.(metricdata.Histogram[float64])
+	if len(hist.DataPoints) != 1 || hist.DataPoints[0].Count != 1 {
+		t.Errorf("duration data points = %+v, want one sample", hist.DataPoints)
+	}
+}
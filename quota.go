@@ -0,0 +1,180 @@
+package viesapi
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Polling interval is zero or negative
+var ErrInterval = errors.New("viesapi: interval must be positive")
+
+// Kind of quota alert
+type QuotaAlertKind int
+
+const (
+	// Request usage reached a threshold of the plan limit
+	QuotaUsage QuotaAlertKind = iota + 1
+	// Account expires within the configured period
+	QuotaExpiry
+)
+
+// Alert fired by QuotaWatcher
+type QuotaAlert struct {
+	Kind      QuotaAlertKind
+	Threshold float64       // crossed usage threshold, QuotaUsage only
+	Usage     float64       // TotalCount divided by Limit
+	Remaining time.Duration // time left until ValidTo, QuotaExpiry only
+	Status    AccountStatus
+}
+
+// QuotaOption configures QuotaWatcher
+type QuotaOption func(*QuotaWatcher)
+
+// Fire usage alerts at specified fractions of the plan limit, 0.8, 0.95 and 1 by default
+func WithThresholds(thresholds ...float64) QuotaOption {
+	return func(w *QuotaWatcher) {
+		w.thresholds = append([]float64(nil), thresholds...)
+		sort.Float64s(w.thresholds)
+	}
+}
+
+// Fire expiry alert when the account expires within specified number of days, 7 by default
+func WithExpiryWarning(days int) QuotaOption {
+	return func(w *QuotaWatcher) {
+		w.expiry = time.Duration(days) * 24 * time.Hour
+	}
+}
+
+// Call specified function for every alert
+func OnQuotaAlert(fn func(QuotaAlert)) QuotaOption {
+	return func(w *QuotaWatcher) {
+		w.onAlert = fn
+	}
+}
+
+// Call specified function when polling the account status fails
+func OnQuotaError(fn func(*ViesError)) QuotaOption {
+	return func(w *QuotaWatcher) {
+		w.onError = fn
+	}
+}
+
+// QuotaWatcher polls account status and alerts before the service is lost
+// because of exhausted request limit or subscription expiry
+type QuotaWatcher struct {
+	client     *VIESClient
+	interval   time.Duration
+	thresholds []float64
+	expiry     time.Duration
+	onAlert    func(QuotaAlert)
+	onError    func(*ViesError)
+
+	mu      sync.Mutex
+	status  *AccountStatus
+	fired   map[float64]bool
+	expired bool
+}
+
+// Create new QuotaWatcher polling account status of the client every interval
+func NewQuotaWatcher(client *VIESClient, interval time.Duration, opts ...QuotaOption) *QuotaWatcher {
+	w := &QuotaWatcher{
+		client:     client,
+		interval:   interval,
+		thresholds: []float64{0.8, 0.95, 1},
+		expiry:     7 * 24 * time.Hour,
+		fired:      make(map[float64]bool),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Poll account status every interval until the context is done
+func (w *QuotaWatcher) Run(ctx context.Context) error {
+	if w.interval <= 0 {
+		return ErrInterval
+	}
+	t := time.NewTicker(w.interval)
+	defer t.Stop()
+
+	for {
+		w.Check(ctx)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Poll account status once and fire alerts for newly crossed limits
+func (w *QuotaWatcher) Check(ctx context.Context) *ViesError {
+	status, e := w.client.GetAccountStatusContext(ctx)
+	if e != nil {
+		if w.onError != nil {
+			w.onError(e)
+		}
+		return e
+	}
+
+	alerts := w.update(status)
+	if w.onAlert != nil {
+		for _, a := range alerts {
+			w.onAlert(a)
+		}
+	}
+	return nil
+}
+
+// Get copy of the last polled account status or nil if none succeeded yet
+func (w *QuotaWatcher) Status() *AccountStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.status == nil {
+		return nil
+	}
+	status := *w.status
+	return &status
+}
+
+// Store status and return alerts for limits crossed since the previous one
+func (w *QuotaWatcher) update(status *AccountStatus) []QuotaAlert {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.status = status
+	var alerts []QuotaAlert
+
+	if status.Limit > 0 {
+		usage := float64(status.TotalCount) / float64(status.Limit)
+		for _, th := range w.thresholds {
+			if usage < th {
+				// usage dropped after a new billing period started
+				w.fired[th] = false
+				continue
+			}
+			if !w.fired[th] {
+				w.fired[th] = true
+				alerts = append(alerts, QuotaAlert{Kind: QuotaUsage, Threshold: th, Usage: usage, Status: *status})
+			}
+		}
+	}
+
+	if status.ValidTo != nil {
+		remaining := status.ValidTo.Sub(w.client.clock())
+		if remaining > w.expiry {
+			// subscription was renewed
+			w.expired = false
+		} else if !w.expired {
+			w.expired = true
+			alerts = append(alerts, QuotaAlert{Kind: QuotaExpiry, Remaining: remaining, Status: *status})
+		}
+	}
+
+	return alerts
+}
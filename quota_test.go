@@ -0,0 +1,100 @@
+package viesapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestQuotaWatcher(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	total := 0
+	validTo := "2024-12-31T23:59:59"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<result><account><uid>uid</uid><validTo>%s</validTo><billingPlan><limit>100</limit></billingPlan><requests><total>%d</total></requests></account><error><code>0</code></error></result>`, validTo, total)
+	}))
+	defer server.Close()
+
+	c := NewVIESClient("test_id", "test_key", WithClock(func() time.Time { return now }))
+	c.SetUrl(server.URL)
+
+	var alerts []QuotaAlert
+	w := NewQuotaWatcher(c, time.Hour,
+		WithThresholds(0.95, 0.8),
+		WithExpiryWarning(10),
+		OnQuotaAlert(func(a QuotaAlert) { alerts = append(alerts, a) }))
+
+	if w.Status() != nil {
+		t.Error("Status() should be nil before first check")
+	}
+
+	steps := []struct {
+		total   int
+		validTo string
+		want    []QuotaAlert
+	}{
+		{50, "2024-12-31T23:59:59", nil},
+		{85, "2024-12-31T23:59:59", []QuotaAlert{{Kind: QuotaUsage, Threshold: 0.8}}},
+		{90, "2024-12-31T23:59:59", nil},
+		{97, "2024-06-05T00:00:00", []QuotaAlert{{Kind: QuotaUsage, Threshold: 0.95}, {Kind: QuotaExpiry}}},
+		{99, "2024-06-05T00:00:00", nil},
+		// new period and renewed subscription re-arm all alerts
+		{10, "2025-06-05T00:00:00", nil},
+		{81, "2025-06-05T00:00:00", []QuotaAlert{{Kind: QuotaUsage, Threshold: 0.8}}},
+	}
+
+	for i, step := range steps {
+		total, validTo, alerts = step.total, step.validTo, nil
+		if e := w.Check(context.Background()); e != nil {
+			t.Fatalf("step %d: Check returned error: %v", i, e)
+		}
+		if len(alerts) != len(step.want) {
+			t.Fatalf("step %d: alerts = %+v, want %+v", i, alerts, step.want)
+		}
+		for j, a := range alerts {
+			if a.Kind != step.want[j].Kind || a.Threshold != step.want[j].Threshold {
+				t.Errorf("step %d: alert %d = %+v, want %+v", i, j, a, step.want[j])
+			}
+		}
+		if s := w.Status(); s == nil || s.TotalCount != step.total {
+			t.Errorf("step %d: Status() = %v, want total %d", i, s, step.total)
+		}
+	}
+}
+
+func TestQuotaWatcherError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<result><error><code>102</code><description>Auth error</description></error></result>`))
+	}))
+	defer server.Close()
+
+	c := NewVIESClient("test_id", "test_key")
+	c.SetUrl(server.URL)
+
+	var got *ViesError
+	w := NewQuotaWatcher(c, time.Hour, OnQuotaError(func(e *ViesError) { got = e }))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := w.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run = %v, want deadline exceeded", err)
+	}
+
+	if got == nil || got.Code != 102 {
+		t.Errorf("error = %v, want code 102", got)
+	}
+	if w.Status() != nil {
+		t.Error("Status() should stay nil after failed checks")
+	}
+}
+
+func TestQuotaWatcherInterval(t *testing.T) {
+	w := NewQuotaWatcher(NewVIESClient("test_id", "test_key"), 0)
+	if err := w.Run(context.Background()); !errors.Is(err, ErrInterval) {
+		t.Errorf("Run = %v, want ErrInterval", err)
+	}
+}
@@ -0,0 +1,224 @@
+package viesapi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Default max size of the response body
+const defaultMaxResponseSize = 1 << 20
+
+var (
+	// Response body exceeds the max response size
+	ErrResponseTooLarge = errors.New("viesapi: response exceeds size limit")
+	// Response lacks an element required to build the result
+	ErrMissingElement = errors.New("viesapi: response is missing required element")
+)
+
+// Max length of the response body kept in ResponseError
+const snippetSize = 512
+
+// Unexpected HTTP response of the service, carried by ViesError and available
+// with errors.As
+type ResponseError struct {
+	StatusCode int
+	Header     http.Header
+	Body       string        // beginning of the response body
+	RetryAfter time.Duration // zero if the service did not specify it
+}
+
+// Return status of the response
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("viesapi: unexpected response %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Check if the response has success status and is not an HTML page
+func succeeded(res *http.Response) bool {
+	media, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	return res.StatusCode >= 200 && res.StatusCode < 300 && media != "text/html"
+}
+
+// Check HTTP status and content type of the response. Errors reported by the
+// service in the XML body are left to the caller, whatever the status.
+func (c *VIESClient) checkResponse(res *http.Response, body []byte) *ViesError {
+
+	if succeeded(res) || c.responseCode(body) != 0 {
+		return nil
+	}
+	media, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+
+	rerr := &ResponseError{
+		StatusCode: res.StatusCode,
+		Header:     res.Header.Clone(),
+		Body:       string(body[:min(len(body), snippetSize)]),
+	}
+
+	var e *ViesError
+	switch {
+	case res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden:
+		e = c.newError(CLI_AUTH, "")
+	case res.StatusCode == http.StatusTooManyRequests:
+		rerr.RetryAfter = c.retryAfter(res.Header.Get("Retry-After"))
+		e = c.newError(CLI_RATE_LIMIT, "")
+	case res.StatusCode >= 500 || media == "text/html":
+		// load balancer errors and maintenance pages are temporary
+		e = c.newError(MAINTENANCE, "VIES API service is temporarily unavailable")
+	default:
+		e = c.newError(CLI_RESPONSE, "")
+	}
+	e.err = rerr
+	return e
+}
+
+// Parse Retry-After header given either in seconds or as HTTP date
+func (c *VIESClient) retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := t.Sub(c.clock()); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// Wire format of the service responses
+type ResponseFormat int
+
+const (
+	// XML documents, the service default
+	ResponseXML ResponseFormat = iota
+	// JSON documents
+	ResponseJSON
+)
+
+// Return format name
+func (f ResponseFormat) String() string {
+	switch f {
+	case ResponseXML:
+		return "xml"
+	case ResponseJSON:
+		return "json"
+	}
+	return "ResponseFormat(" + strconv.Itoa(int(f)) + ")"
+}
+
+// Get content of the Accept header for the selected response format
+func (c *VIESClient) accept() string {
+	if c.format == ResponseJSON {
+		return "application/json"
+	}
+	return "application/xml"
+}
+
+// Decoded response envelope reporting the error code of the service
+type response interface {
+	errorCode() int
+	// clear decoded content before the response of a retry is decoded
+	reset()
+}
+
+func (d *viesData) errorCode() int {
+	return d.Error.Code
+}
+
+func (d *viesData) reset() {
+	*d = viesData{}
+}
+
+func (d *viesAccountStatus) errorCode() int {
+	return d.Error.Code
+}
+
+func (d *viesAccountStatus) reset() {
+	*d = viesAccountStatus{}
+}
+
+// Reader counting bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// Decode buffered response body into v, unknown elements are ignored
+func (c *VIESClient) unmarshal(body []byte, v any) error {
+	return c.decodeFrom(bytes.NewReader(body), v)
+}
+
+// Decode XML or JSON response while reading it, in the format it was sent,
+// which may differ from the requested one if the service or a proxy ignored
+// the Accept header. Unknown elements are ignored.
+func (c *VIESClient) decodeFrom(r io.Reader, v any) error {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.Peek(1)
+		if err != nil || !unicode.IsSpace(rune(b[0])) {
+			break
+		}
+		br.ReadByte()
+	}
+	if b, err := br.Peek(1); err == nil && b[0] == '{' {
+		return json.NewDecoder(br).Decode(v)
+	}
+	return xml.NewDecoder(br).Decode(v)
+}
+
+// Build VIES data checking that all required elements are present
+func (r *viesRecord) data() (*VIESData, error) {
+	if r == nil {
+		return nil, fmt.Errorf("%w: vies", ErrMissingElement)
+	}
+
+	var missing []string
+	str := func(name string, p *string) string {
+		if p == nil {
+			missing = append(missing, name)
+			return ""
+		}
+		return *p
+	}
+	data := &VIESData{
+		UID:               str("uid", r.UID),
+		CountryCode:       str("countryCode", r.CountryCode),
+		VATNumber:         str("vatNumber", r.VATNumber),
+		TraderName:        r.TraderName,
+		TraderCompanyType: r.TraderCompanyType,
+		TraderAddress:     r.TraderAddress,
+		ID:                r.ID,
+		Source:            r.Source,
+		Stale:             r.Stale,
+		Degraded:          r.Degraded,
+	}
+	if r.Valid == nil {
+		missing = append(missing, "valid")
+	} else {
+		data.Valid = *r.Valid
+	}
+	data.Date = str("date", r.Date)
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrMissingElement, strings.Join(missing, ", "))
+	}
+	return data, nil
+}
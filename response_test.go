@@ -0,0 +1,205 @@
+package viesapi
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResponseStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		retryAfter  string
+		body        string
+		code        int
+		wantRetry   time.Duration
+	}{
+		{"ok", 200, "application/xml", "", `<result><vies><valid>true</valid><uid>test-uid</uid><countryCode>PL</countryCode><vatNumber>7272445205</vatNumber><date>2024-01-15</date></vies><error><code>0</code></error></result>`, 0, 0},
+		{"unauthorized", 401, "text/plain", "", "Unauthorized", CLI_AUTH, 0},
+		{"forbidden", 403, "text/html", "", "<html>Forbidden</html>", CLI_AUTH, 0},
+		{"throttled", 429, "text/plain", "30", "slow down", CLI_RATE_LIMIT, 30 * time.Second},
+		{"throttled date", 429, "text/plain", "Mon, 15 Jan 2024 10:01:00 GMT", "", CLI_RATE_LIMIT, time.Minute},
+		{"bad gateway", 502, "text/html", "", "<html>502 Bad Gateway</html>", MAINTENANCE, 0},
+		{"maintenance page", 200, "text/html; charset=utf-8", "", "<html>Maintenance</html>", MAINTENANCE, 0},
+		{"not found", 404, "text/plain", "", "not found", CLI_RESPONSE, 0},
+		{"service error", 403, "application/xml", "", `<result><error><code>55</code><description>bad mac</description></error></result>`, AUTH_MAC, 0},
+	}
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			c := NewVIESClient("test_id", "test_key", WithClock(func() time.Time { return now }))
+			c.SetUrl(server.URL)
+
+			_, e := c.GetVIESData("PL7272445205")
+			code := 0
+			if e != nil {
+				code = e.Code
+			}
+			if code != tt.code {
+				t.Fatalf("error = %v, want code %d", e, tt.code)
+			}
+
+			if e == nil {
+				return
+			}
+			var rerr *ResponseError
+			if !errors.As(e, &rerr) {
+				if tt.code != AUTH_MAC {
+					t.Errorf("error %v does not carry ResponseError", e)
+				}
+				return
+			}
+			if rerr.StatusCode != tt.status || rerr.Body != tt.body || rerr.Header.Get("Content-Type") != tt.contentType {
+				t.Errorf("ResponseError = %+v, want status %d and body %q", rerr, tt.status, tt.body)
+			}
+			if rerr.RetryAfter != tt.wantRetry {
+				t.Errorf("RetryAfter = %v, want %v", rerr.RetryAfter, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestResponseSnippet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(strings.Repeat("x", 4096)))
+	}))
+	defer server.Close()
+
+	c := NewVIESClient("test_id", "test_key")
+	c.SetUrl(server.URL)
+
+	_, e := c.GetAccountStatus()
+	var rerr *ResponseError
+	if !errors.As(e, &rerr) {
+		t.Fatalf("error = %v, want ResponseError", e)
+	}
+	if len(rerr.Body) != snippetSize {
+		t.Errorf("body snippet length = %d, want %d", len(rerr.Body), snippetSize)
+	}
+	if code, _ := c.GetLastError(); code != MAINTENANCE {
+		t.Errorf("last error = %d, want %d", code, MAINTENANCE)
+	}
+}
+
+func TestResponseDecode(t *testing.T) {
+	const full = `<uid>test-uid</uid><countryCode>PL</countryCode><vatNumber>7272445205</vatNumber><valid>false</valid><date>2024-01-15</date>`
+
+	tests := []struct {
+		name    string
+		body    string
+		code    int
+		missing string
+	}{
+		{"complete", `<result><vies>` + full + `</vies><error><code>0</code></error></result>`, 0, ""},
+		{"unknown elements", `<result><vies>` + full + `<vatGroup>none</vatGroup></vies><extra/><error><code>0</code></error></result>`, 0, ""},
+		{"missing vies", `<result><error><code>0</code></error></result>`, CLI_RESPONSE, "vies"},
+		{"missing elements", `<result><vies><uid>test-uid</uid><countryCode>PL</countryCode></vies></result>`, CLI_RESPONSE, "vatNumber, valid, date"},
+		{"truncated", `<result><vies>` + full[:40], CLI_RESPONSE, ""},
+		{"service error", `<result><error><code>22</code><description>EU VAT ID is invalid</description></error></result>`, EUVAT_BAD, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			c := NewVIESClient("test_id", "test_key")
+			c.SetUrl(server.URL)
+
+			data, e := c.GetVIESData("PL7272445205")
+			if tt.code == 0 {
+				if e != nil || data == nil || data.Valid || data.Date != "2024-01-15" {
+					t.Errorf("GetVIESData = %v, %v; want invalid result", data, e)
+				}
+				return
+			}
+			if e == nil || e.Code != tt.code {
+				t.Fatalf("error = %v, want code %d", e, tt.code)
+			}
+			if tt.missing != "" && (!errors.Is(e, ErrMissingElement) || !strings.HasSuffix(errors.Unwrap(e).Error(), tt.missing)) {
+				t.Errorf("error = %v, want missing %s", errors.Unwrap(e), tt.missing)
+			}
+		})
+	}
+}
+
+func TestResponseMissingAccount(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<result><error><code>0</code></error></result>`))
+	}))
+	defer server.Close()
+
+	c := NewVIESClient("test_id", "test_key")
+	c.SetUrl(server.URL)
+
+	if _, e := c.GetAccountStatus(); e == nil || !errors.Is(e, ErrMissingElement) {
+		t.Errorf("error = %v, want ErrMissingElement", e)
+	}
+}
+
+func TestMaxResponseSize(t *testing.T) {
+	body := `<result><vies><uid>test-uid</uid><countryCode>PL</countryCode><vatNumber>7272445205</vatNumber><valid>true</valid><date>2024-01-15</date></vies></result>`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		limit int64
+		ok    bool
+	}{
+		{int64(len(body)), true},
+		{int64(len(body)) - 1, false},
+		{0, true},
+		{-1, true},
+	}
+
+	for _, tt := range tests {
+		c := NewVIESClient("test_id", "test_key", WithMaxResponseSize(tt.limit))
+		c.SetUrl(server.URL)
+
+		_, e := c.GetVIESData("PL7272445205")
+		if tt.ok != (e == nil) {
+			t.Errorf("limit %d: error = %v, want ok %v", tt.limit, e, tt.ok)
+		}
+		if !tt.ok && (e == nil || e.Code != CLI_RESPONSE || !errors.Is(e, ErrResponseTooLarge)) {
+			t.Errorf("limit %d: error = %v, want ErrResponseTooLarge", tt.limit, e)
+		}
+	}
+}
+
+func TestMaxResponseSizeTrailing(t *testing.T) {
+	// data after the decoded document still counts against the limit
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<result><vies><uid>test-uid</uid><countryCode>PL</countryCode><vatNumber>7272445205</vatNumber><valid>true</valid><date>2024-01-15</date></vies></result>`))
+		w.Write(bytes.Repeat([]byte(" "), 4096))
+	}))
+	defer server.Close()
+
+	c := NewVIESClient("test_id", "test_key", WithMaxResponseSize(1024))
+	c.SetUrl(server.URL)
+
+	if _, e := c.GetVIESData("PL7272445205"); e == nil || !errors.Is(e, ErrResponseTooLarge) {
+		t.Errorf("error = %v, want ErrResponseTooLarge", e)
+	}
+}
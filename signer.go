@@ -0,0 +1,160 @@
+package viesapi
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// Authorization header is not a well-formed MAC header
+	ErrAuthorization = errors.New("viesapi: malformed MAC authorization header")
+	// Authorization header MAC does not match the request
+	ErrMAC = errors.New("viesapi: authorization MAC mismatch")
+)
+
+var reAuthParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// Signer creates and verifies MAC authorization headers of the VIES API service
+type Signer struct {
+	id    string
+	key   string
+	clock func() time.Time
+	nonce func() (string, error)
+}
+
+// Create new Signer for specified id and key. Nil clock and nonce default to
+// time.Now and 4 random bytes in hex form.
+func NewSigner(id, key string, clock func() time.Time, nonce func() (string, error)) *Signer {
+	if clock == nil {
+		clock = time.Now
+	}
+	if nonce == nil {
+		nonce = func() (string, error) {
+			return randomHex(4)
+		}
+	}
+	return &Signer{
+		id:    id,
+		key:   key,
+		clock: clock,
+		nonce: nonce,
+	}
+}
+
+// Sign request with specified method and url and return authorization header content
+func (s *Signer) Sign(method, urlstr string) (string, error) {
+
+	nonce, err := s.nonce()
+	if err != nil {
+		return "", fmt.Errorf("viesapi: nonce: %w", err)
+	}
+	ts := s.clock().Unix()
+
+	input, err := s.canonical(ts, nonce, method, urlstr)
+	if err != nil {
+		return "", err
+	}
+	mac := MAC(s.key, input)
+
+	return fmt.Sprintf(`MAC id="%s", ts="%d", nonce="%s", mac="%s"`, s.id, ts, nonce, mac), nil
+}
+
+// Verify authorization header of request with specified method and url and
+// return its signing time. The time window is left for the caller to check.
+func (s *Signer) Verify(method, urlstr, header string) (time.Time, error) {
+
+	if !strings.HasPrefix(header, "MAC ") {
+		return time.Time{}, ErrAuthorization
+	}
+	params := make(map[string]string)
+	for _, m := range reAuthParam.FindAllStringSubmatch(header, -1) {
+		params[m[1]] = m[2]
+	}
+	ts, err := strconv.ParseInt(params["ts"], 10, 64)
+	if err != nil || params["id"] != s.id {
+		return time.Time{}, ErrAuthorization
+	}
+
+	input, err := s.canonical(ts, params["nonce"], method, urlstr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	mac, err := base64.StdEncoding.DecodeString(params["mac"])
+	if err != nil {
+		return time.Time{}, ErrAuthorization
+	}
+	expected, _ := base64.StdEncoding.DecodeString(MAC(s.key, input))
+	if !hmac.Equal(mac, expected) {
+		return time.Time{}, ErrMAC
+	}
+	return time.Unix(ts, 0), nil
+}
+
+// Build canonical string covered by the MAC
+func (s *Signer) canonical(ts int64, nonce, method, urlstr string) (string, error) {
+
+	//parse url
+	url, err := url.Parse(urlstr)
+	if err != nil {
+		return "", err
+	}
+	host := url.Host
+	port := "80"
+	if url.Scheme == "https" {
+		port = "443"
+	}
+
+	i := strings.LastIndexByte(host, ':')
+	if i > 0 {
+		host = host[:i]
+		port = url.Host[i+1:]
+	}
+
+	return fmt.Sprintf("%d\n%s\n%s\n%s\n%s\n%s\n\n", ts, nonce, method, url.Path, host, port), nil
+}
+
+// Get key identifier from MAC authorization header content
+func AuthorizationID(header string) string {
+	return authParam(header, "id")
+}
+
+// Get nonce from MAC authorization header content
+func AuthorizationNonce(header string) string {
+	return authParam(header, "nonce")
+}
+
+// Get named parameter of MAC authorization header content
+func authParam(header, name string) string {
+	for _, m := range reAuthParam.FindAllStringSubmatch(header, -1) {
+		if m[1] == name {
+			return m[2]
+		}
+	}
+	return ""
+}
+
+// Calculates HMAC256 from input string with specified key in base64 form
+func MAC(key, input string) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write([]byte(input))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Get n random bytes in hex form
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
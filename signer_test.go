@@ -0,0 +1,114 @@
+package viesapi
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func fixedSigner() *Signer {
+	return NewSigner("test_id", "test_key",
+		func() time.Time { return time.Unix(1700000000, 0) },
+		func() (string, error) { return "0a1b2c3d", nil })
+}
+
+func TestSignerCanonical(t *testing.T) {
+	s := fixedSigner()
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://viesapi.eu/api/get/vies/euvat/PL7272445205", "1700000000\n0a1b2c3d\nGET\n/api/get/vies/euvat/PL7272445205\nviesapi.eu\n443\n\n"},
+		{"http://localhost:8080/api/check/account/status", "1700000000\n0a1b2c3d\nGET\n/api/check/account/status\nlocalhost\n8080\n\n"},
+		{"http://viesapi.eu/api", "1700000000\n0a1b2c3d\nGET\n/api\nviesapi.eu\n80\n\n"},
+	}
+
+	for _, tt := range tests {
+		got, err := s.canonical(1700000000, "0a1b2c3d", "GET", tt.url)
+		if err != nil {
+			t.Fatalf("canonical(%s) returned error: %v", tt.url, err)
+		}
+		if got != tt.want {
+			t.Errorf("canonical(%s) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestSignerSign(t *testing.T) {
+	s := fixedSigner()
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://viesapi.eu/api/get/vies/euvat/PL7272445205", `MAC id="test_id", ts="1700000000", nonce="0a1b2c3d", mac="79czL44UGQeB3YmxQZmbw1D1icvaRMYRi5im/wELadg="`},
+		{"http://localhost:8080/api/check/account/status", `MAC id="test_id", ts="1700000000", nonce="0a1b2c3d", mac="DKSpRpEgJ75mRZd6JcgdVkeT6vzMma/PIM4ts9QuWDM="`},
+	}
+
+	for _, tt := range tests {
+		got, err := s.Sign("GET", tt.url)
+		if err != nil {
+			t.Fatalf("Sign(%s) returned error: %v", tt.url, err)
+		}
+		if got != tt.want {
+			t.Errorf("Sign(%s) = %s, want %s", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestSignerErrors(t *testing.T) {
+	failing := errors.New("no entropy")
+	s := NewSigner("test_id", "test_key", nil, func() (string, error) { return "", failing })
+	if _, err := s.Sign("GET", "https://viesapi.eu/api"); !errors.Is(err, failing) {
+		t.Errorf("Sign error = %v, want nonce error", err)
+	}
+
+	s = fixedSigner()
+	if _, err := s.Sign("GET", "://bad url"); err == nil {
+		t.Error("Sign should fail for invalid url")
+	}
+}
+
+func TestSignerVerify(t *testing.T) {
+	s := fixedSigner()
+	url := "https://viesapi.eu/api/get/vies/euvat/PL7272445205"
+	header, _ := s.Sign("GET", url)
+
+	ts, err := s.Verify("GET", url, header)
+	if err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+	if ts.Unix() != 1700000000 {
+		t.Errorf("Verify ts = %d, want 1700000000", ts.Unix())
+	}
+	if id := AuthorizationID(header); id != "test_id" {
+		t.Errorf("AuthorizationID = %s, want test_id", id)
+	}
+
+	if _, err := s.Verify("GET", url+"0", header); !errors.Is(err, ErrMAC) {
+		t.Errorf("Verify with other url = %v, want ErrMAC", err)
+	}
+	other := NewSigner("test_id", "other_key", nil, nil)
+	if _, err := other.Verify("GET", url, header); !errors.Is(err, ErrMAC) {
+		t.Errorf("Verify with other key = %v, want ErrMAC", err)
+	}
+	if _, err := s.Verify("GET", url, "Bearer token"); !errors.Is(err, ErrAuthorization) {
+		t.Errorf("Verify with bearer token = %v, want ErrAuthorization", err)
+	}
+}
+
+func TestClientDeterministicAuth(t *testing.T) {
+	c := NewVIESClient("test_id", "test_key",
+		WithClock(func() time.Time { return time.Unix(1700000000, 0) }),
+		WithNonce(func() (string, error) { return "0a1b2c3d", nil }))
+
+	auth, err := c.auth(c.creds, "GET", "https://viesapi.eu/api/get/vies/euvat/PL7272445205")
+	if err != nil {
+		t.Fatalf("auth returned error: %v", err)
+	}
+	want := `MAC id="test_id", ts="1700000000", nonce="0a1b2c3d", mac="79czL44UGQeB3YmxQZmbw1D1icvaRMYRi5im/wELadg="`
+	if auth != want {
+		t.Errorf("auth = %s, want %s", auth, want)
+	}
+}
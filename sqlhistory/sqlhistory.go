@@ -0,0 +1,164 @@
+// Package sqlhistory implements viesapi.HistoryStore on top of database/sql.
+//
+// The store works with any driver using either question mark (SQLite, MySQL)
+// or numbered dollar (PostgreSQL) placeholders. Check times are kept as Unix
+// nanoseconds so that range queries behave the same on every database.
+package sqlhistory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/glaydus/viesapi"
+)
+
+// SQL dialect of the database
+type Dialect int
+
+const (
+	SQLite Dialect = iota
+	Postgres
+	MySQL
+)
+
+const columns = "number, checked, valid, country_code, vat_number, trader_name, trader_company_type, trader_address, request_id, uid, date, source"
+
+// Store is a viesapi.HistoryStore kept in an SQL table
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+	table   string
+}
+
+// Create new Store using table with specified name in db
+func New(db *sql.DB, dialect Dialect, table string) *Store {
+	return &Store{db: db, dialect: dialect, table: table}
+}
+
+// Create the history table and its indexes unless they exist
+func (s *Store) CreateTable(ctx context.Context) error {
+
+	// MySQL has no CREATE INDEX IF NOT EXISTS, so its indexes are declared inline
+	indexes := ""
+	if s.dialect == MySQL {
+		indexes = `,
+			INDEX ` + s.table + `_number_checked (number, checked),
+			INDEX ` + s.table + `_checked (checked)`
+	}
+
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS ` + s.table + ` (
+			number VARCHAR(16) NOT NULL,
+			checked BIGINT NOT NULL,
+			valid BOOLEAN NOT NULL,
+			country_code VARCHAR(2) NOT NULL,
+			vat_number VARCHAR(16) NOT NULL,
+			trader_name TEXT NOT NULL,
+			trader_company_type VARCHAR(255) NOT NULL,
+			trader_address TEXT NOT NULL,
+			request_id VARCHAR(64) NOT NULL,
+			uid VARCHAR(64) NOT NULL,
+			date VARCHAR(32) NOT NULL,
+			source VARCHAR(255) NOT NULL` + indexes + `
+		)`,
+	}
+	if s.dialect != MySQL {
+		stmts = append(stmts,
+			`CREATE INDEX IF NOT EXISTS `+s.table+`_number_checked ON `+s.table+` (number, checked)`,
+			`CREATE INDEX IF NOT EXISTS `+s.table+`_checked ON `+s.table+` (checked)`)
+	}
+
+	for _, stmt := range stmts {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Store single result
+func (s *Store) Save(ctx context.Context, rec *viesapi.HistoryRecord) error {
+	d := &rec.Data
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO "+s.table+" ("+columns+") VALUES ("+s.placeholders(1, 12)+")",
+		rec.Number, rec.Checked.UnixNano(), d.Valid, d.CountryCode, d.VATNumber, d.TraderName,
+		d.TraderCompanyType, d.TraderAddress, d.ID, d.UID, d.Date, d.Source)
+	return err
+}
+
+// Get results matching the criteria ordered by check time
+func (s *Store) Query(ctx context.Context, q viesapi.HistoryQuery) ([]viesapi.HistoryRecord, error) {
+
+	var where []string
+	var args []any
+	cond := func(expr string, arg any) {
+		args = append(args, arg)
+		where = append(where, expr+" "+s.placeholder(len(args)))
+	}
+
+	if q.Number != "" {
+		cond("number =", q.Number)
+	}
+	if !q.From.IsZero() {
+		cond("checked >=", q.From.UnixNano())
+	}
+	if !q.To.IsZero() {
+		cond("checked <=", q.To.UnixNano())
+	}
+	if q.Valid != nil {
+		cond("valid =", *q.Valid)
+	}
+
+	query := "SELECT " + columns + " FROM " + s.table
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY checked"
+	if q.Newest {
+		query += " DESC"
+	}
+	if q.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", q.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []viesapi.HistoryRecord
+	for rows.Next() {
+		var rec viesapi.HistoryRecord
+		var checked int64
+		d := &rec.Data
+		err := rows.Scan(&rec.Number, &checked, &d.Valid, &d.CountryCode, &d.VATNumber, &d.TraderName,
+			&d.TraderCompanyType, &d.TraderAddress, &d.ID, &d.UID, &d.Date, &d.Source)
+		if err != nil {
+			return nil, err
+		}
+		rec.Checked = time.Unix(0, checked)
+		res = append(res, rec)
+	}
+	return res, rows.Err()
+}
+
+// Get placeholder for n-th argument
+func (s *Store) placeholder(n int) string {
+	if s.dialect == Postgres {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
+
+// Get comma separated placeholders for arguments from first to last
+func (s *Store) placeholders(first, last int) string {
+	p := make([]string, 0, last-first+1)
+	for n := first; n <= last; n++ {
+		p = append(p, s.placeholder(n))
+	}
+	return strings.Join(p, ", ")
+}
@@ -0,0 +1,135 @@
+package sqlhistory
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/glaydus/viesapi"
+	_ "modernc.org/sqlite"
+)
+
+func newStore(t *testing.T) *Store {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	s := New(db, SQLite, "vies_history")
+	if err := s.CreateTable(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// second call must be a no-op
+	if err := s.CreateTable(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestStoreClient(t *testing.T) {
+	valid := "true"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<result><vies><uid>test-uid</uid><countryCode>PL</countryCode><vatNumber>7272445205</vatNumber><valid>` + valid + `</valid><traderName>Test</traderName><date>2024-01-15</date></vies><error><code>0</code></error></result>`))
+	}))
+	defer server.Close()
+
+	store := newStore(t)
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	c := viesapi.NewVIESClient("test_id", "test_key", viesapi.WithHistory(store), viesapi.WithClock(func() time.Time { return now }))
+	c.SetUrl(server.URL)
+
+	c.GetVIESData("PL7272445205")
+	now = now.Add(24 * time.Hour)
+	valid = "false"
+	c.GetVIESData("PL7272445205")
+
+	ctx := context.Background()
+	recs, err := store.Query(ctx, viesapi.HistoryQuery{Number: "PL7272445205"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 2 || !recs[0].Data.Valid || recs[1].Data.Valid {
+		t.Fatalf("records = %+v, want valid then invalid", recs)
+	}
+	if recs[0].Data.UID != "test-uid" || recs[0].Data.TraderName != "Test" || !recs[1].Checked.Equal(now) {
+		t.Errorf("record = %+v, want stored data", recs[1])
+	}
+
+	rec, err := viesapi.HistoryAt(ctx, store, "PL7272445205", now.Add(-time.Hour))
+	if err != nil || rec == nil || !rec.Data.Valid {
+		t.Errorf("HistoryAt day before = %+v, %v; want valid", rec, err)
+	}
+	if rec, _ := viesapi.HistoryAt(ctx, store, "PL7272445205", now.Add(-48*time.Hour)); rec != nil {
+		t.Errorf("HistoryAt before first check = %+v, want nil", rec)
+	}
+}
+
+func TestStoreQuery(t *testing.T) {
+	store := newStore(t)
+	ctx := context.Background()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	numbers := []string{"PL7272445205", "DE123456789", "PL7272445205", "DE123456789"}
+	for i, number := range numbers {
+		rec := &viesapi.HistoryRecord{
+			Number:  number,
+			Checked: base.Add(time.Duration(i) * time.Hour),
+			Data:    viesapi.VIESData{Valid: i%2 == 0, VATNumber: number[2:], CountryCode: number[:2]},
+		}
+		if err := store.Save(ctx, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	valid := true
+	tests := []struct {
+		name  string
+		query viesapi.HistoryQuery
+		want  []int // hours of expected records
+	}{
+		{"all", viesapi.HistoryQuery{}, []int{0, 1, 2, 3}},
+		{"number", viesapi.HistoryQuery{Number: "DE123456789"}, []int{1, 3}},
+		{"range", viesapi.HistoryQuery{From: base.Add(time.Hour), To: base.Add(2 * time.Hour)}, []int{1, 2}},
+		{"valid", viesapi.HistoryQuery{Valid: &valid}, []int{0, 2}},
+		{"newest", viesapi.HistoryQuery{Newest: true, Limit: 2}, []int{3, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recs, err := store.Query(ctx, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(recs) != len(tt.want) {
+				t.Fatalf("got %d records, want %d", len(recs), len(tt.want))
+			}
+			for i, h := range tt.want {
+				if want := base.Add(time.Duration(h) * time.Hour); !recs[i].Checked.Equal(want) {
+					t.Errorf("record %d checked = %v, want %v", i, recs[i].Checked, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{SQLite, "?, ?, ?"},
+		{MySQL, "?, ?, ?"},
+		{Postgres, "$2, $3, $4"},
+	}
+
+	for _, tt := range tests {
+		if got := New(nil, tt.dialect, "t").placeholders(2, 4); got != tt.want {
+			t.Errorf("placeholders(%d) = %q, want %q", tt.dialect, got, tt.want)
+		}
+	}
+}
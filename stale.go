@@ -0,0 +1,106 @@
+package viesapi
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Policy of serving results older than the cache ttl. Ages are measured from
+// the time the result was fetched from the service, results younger than the
+// ttl found in the history store are served as fresh cache hits.
+type StalePolicy struct {
+	// Serve results up to this age with the Stale flag and refresh them in the background
+	Revalidate time.Duration
+	// Serve results up to this age with the Degraded flag when the lookup fails
+	// because the service or the member state is unavailable
+	MaxStale time.Duration
+}
+
+// Get the last known result for specified normalized number if it is fresh
+// or young enough to be revalidated in the background
+func (c *VIESClient) revalidate(ctx context.Context, key string) *VIESData {
+	last, at := c.lastKnown(ctx, key)
+	if last == nil {
+		return nil
+	}
+
+	switch age := c.clock().Sub(at); {
+	case age < c.ttl:
+		return last
+	case age < c.stale.Revalidate:
+		last.Stale = true
+		c.refresh.Add(1)
+		go func() {
+			defer c.refresh.Done()
+			// keep values such as trace hooks but outlive the caller
+			c.flight.do(context.WithoutCancel(ctx), key, func(ctx context.Context) (*VIESData, *ViesError) {
+				return c.lookup(ctx, key)
+			})
+		}()
+		return last
+	}
+	return nil
+}
+
+// Get the last known result for specified normalized number in place of the
+// failed lookup, nil if the failure is not an outage or the result is too old
+func (c *VIESClient) degraded(ctx context.Context, key string, e *ViesError) *VIESData {
+	if c.stale.MaxStale <= 0 || !unavailable(e.Code) {
+		return nil
+	}
+	last, at := c.lastKnown(ctx, key)
+	if last == nil || c.clock().Sub(at) >= c.stale.MaxStale {
+		return nil
+	}
+
+	last.Stale = true
+	last.Degraded = true
+	if c.logger != nil {
+		number := key
+		if !c.unmask {
+			number = maskNumber(key)
+		}
+		c.logger.LogAttrs(ctx, slog.LevelWarn, "viesapi serving degraded result",
+			slog.String("number", number),
+			slog.Time("fetched", at),
+			slog.Int("code", e.Code),
+		)
+	}
+	return last
+}
+
+// Get the newest result for specified normalized number from the cache or
+// the history store together with the time it was fetched
+func (c *VIESClient) lastKnown(ctx context.Context, key string) (*VIESData, time.Time) {
+	if c.cache != nil {
+		if data, at, ok := c.cache.Get(key); ok {
+			return data, at
+		}
+	}
+	if c.history == nil {
+		return nil, time.Time{}
+	}
+
+	rec, err := HistoryAt(ctx, c.history, key, c.clock())
+	if err != nil {
+		if c.logger != nil {
+			c.logger.LogAttrs(ctx, slog.LevelError, "viesapi history failed", slog.String("error", err.Error()))
+		}
+		return nil, time.Time{}
+	}
+	if rec == nil {
+		return nil, time.Time{}
+	}
+	data := rec.Data
+	return &data, rec.Checked
+}
+
+// Check if the error means the service or the member state could not answer
+func unavailable(code int) bool {
+	switch code {
+	case CLI_CONNECT, CLI_RATE_LIMIT, CLI_CIRCUIT_OPEN, VIES_SYNC, MAINTENANCE:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package viesapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Start server answering VIES data lookups, or failing them with code if it is set
+func staleServer(t *testing.T, hits, code *atomic.Int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if c := code.Load(); c != 0 {
+			w.Write([]byte(`<result><error><code>` + strconv.Itoa(int(c)) + `</code><description>Failure</description></error></result>`))
+			return
+		}
+		w.Write([]byte(`<result><vies><uid>new-uid</uid><countryCode>PL</countryCode><vatNumber>7272445205</vatNumber><valid>true</valid><date>2024-01-15</date></vies></result>`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	var hits, code atomic.Int32
+	server := staleServer(t, &hits, &code)
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	cache := NewMemoryCache(0)
+	c := NewVIESClient("test_id", "test_key",
+		WithCache(cache, time.Minute),
+		WithClock(func() time.Time { return now }),
+		WithStalePolicy(StalePolicy{Revalidate: time.Hour, MaxStale: 24 * time.Hour}),
+	)
+	c.SetUrl(server.URL)
+
+	const number = "PL7272445205"
+	old := &VIESData{UID: "old-uid", CountryCode: "PL", VATNumber: "7272445205", Valid: true, Date: "2024-01-01"}
+
+	// stale entry is served at once and refreshed in the background
+	cache.Set(number, old, now.Add(-10*time.Minute))
+	data, e := c.GetVIESData(number)
+	if e != nil || data.UID != "old-uid" || !data.Stale || data.Degraded {
+		t.Fatalf("GetVIESData = %+v, %v; want stale old-uid", data, e)
+	}
+	c.refresh.Wait()
+	if hits.Load() != 1 {
+		t.Errorf("hits = %d, want 1", hits.Load())
+	}
+	data, _ = c.GetVIESData(number)
+	if data.UID != "new-uid" || data.Stale || hits.Load() != 1 {
+		t.Errorf("after refresh = %+v, hits %d; want fresh new-uid, 1", data, hits.Load())
+	}
+
+	// entry too old to revalidate is fetched synchronously
+	cache.Set(number, old, now.Add(-2*time.Hour))
+	data, _ = c.GetVIESData(number)
+	if data.UID != "new-uid" || data.Stale || hits.Load() != 2 {
+		t.Errorf("old entry = %+v, hits %d; want fresh new-uid, 2", data, hits.Load())
+	}
+}
+
+func TestStaleDegraded(t *testing.T) {
+	var hits, code atomic.Int32
+	server := staleServer(t, &hits, &code)
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	cache := NewMemoryCache(0)
+	c := NewVIESClient("test_id", "test_key",
+		WithCache(cache, time.Minute),
+		WithClock(func() time.Time { return now }),
+		WithStalePolicy(StalePolicy{Revalidate: time.Hour, MaxStale: 24 * time.Hour}),
+	)
+	c.SetUrl(server.URL)
+
+	const number = "PL7272445205"
+	old := &VIESData{UID: "old-uid", CountryCode: "PL", VATNumber: "7272445205", Valid: true, Date: "2024-01-01"}
+
+	tests := []struct {
+		name     string
+		code     int
+		age      time.Duration
+		wantCode int
+	}{
+		{"member state down", VIES_SYNC, 2 * time.Hour, 0},
+		{"maintenance", MAINTENANCE, 23 * time.Hour, 0},
+		{"too old", VIES_SYNC, 25 * time.Hour, VIES_SYNC},
+		{"not an outage", EUVAT_BAD, 2 * time.Hour, EUVAT_BAD},
+	}
+	for _, tt := range tests {
+		code.Store(int32(tt.code))
+		cache.Set(number, old, now.Add(-tt.age))
+
+		data, e := c.GetVIESData(number)
+		if tt.wantCode != 0 {
+			if e == nil || e.Code != tt.wantCode {
+				t.Errorf("%s: error = %v, want code %d", tt.name, e, tt.wantCode)
+			}
+			continue
+		}
+		if e != nil || data.UID != "old-uid" || data.Date != "2024-01-01" || !data.Degraded || !data.Stale {
+			t.Errorf("%s: GetVIESData = %+v, %v; want degraded old-uid", tt.name, data, e)
+		}
+	}
+
+	// cache is not polluted with the flags
+	if data, _, _ := cache.Get(number); data.Stale || data.Degraded {
+		t.Errorf("cached entry = %+v, want no flags", data)
+	}
+}
+
+func TestStaleHistory(t *testing.T) {
+	var hits, code atomic.Int32
+	server := staleServer(t, &hits, &code)
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	history := NewMemoryHistory()
+	c := NewVIESClient("test_id", "test_key",
+		WithCache(NewMemoryCache(0), time.Minute),
+		WithHistory(history),
+		WithClock(func() time.Time { return now }),
+		WithStalePolicy(StalePolicy{MaxStale: 24 * time.Hour}),
+	)
+	c.SetUrl(server.URL)
+
+	const number = "PL7272445205"
+	history.Save(context.Background(), &HistoryRecord{
+		Number:  number,
+		Checked: now.Add(-30 * time.Second),
+		Data:    VIESData{UID: "old-uid", Date: "2024-01-15"},
+	})
+
+	// fresh history hit is served without asking the service
+	data, e := c.GetVIESData(number)
+	if e != nil || data.UID != "old-uid" || data.Stale || hits.Load() != 0 {
+		t.Fatalf("GetVIESData = %+v, %v, hits %d; want fresh old-uid, 0", data, e, hits.Load())
+	}
+
+	// without revalidation stale results are served only on failure
+	now = now.Add(time.Hour)
+	code.Store(VIES_SYNC)
+	data, e = c.GetVIESData(number)
+	if e != nil || data.UID != "old-uid" || !data.Degraded || hits.Load() != 1 {
+		t.Errorf("GetVIESData = %+v, %v, hits %d; want degraded old-uid, 1", data, e, hits.Load())
+	}
+}
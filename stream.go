@@ -0,0 +1,236 @@
+package viesapi
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Default number of concurrent lookups of VerifyStream
+const defaultConcurrency = 4
+
+// Result of a single lookup in VerifyStream
+type Result struct {
+	Input    string
+	Number   string // normalized number, empty if the input is not valid
+	Data     *VIESData
+	Err      *ViesError
+	Latency  time.Duration
+	Attempts int  // requests sent for this input, zero for cache hits, stale results, shared lookups and invalid input
+	Shared   bool // result of a concurrent lookup of the same number started for another input
+}
+
+// Details of a lookup reported back to VerifyStream through the context
+type lookupInfo struct {
+	shared bool
+}
+
+type lookupInfoKey struct{}
+
+// Get lookup details carried by the context or nil
+func contextLookupInfo(ctx context.Context) *lookupInfo {
+	info, _ := ctx.Value(lookupInfoKey{}).(*lookupInfo)
+	return info
+}
+
+// StreamOption configures VerifyStream
+type StreamOption func(*streamOptions)
+
+type streamOptions struct {
+	concurrency int
+	ordered     bool
+	buffer      int
+}
+
+// Run up to n lookups at the same time, 4 by default
+func WithConcurrency(n int) StreamOption {
+	return func(o *streamOptions) {
+		o.concurrency = n
+	}
+}
+
+// Emit results in the order of the input instead of as soon as they are ready
+func WithOrderedResults() StreamOption {
+	return func(o *streamOptions) {
+		o.ordered = true
+	}
+}
+
+// Keep up to n results waiting for the reader before lookups stop, 0 by default
+func WithResultBuffer(n int) StreamOption {
+	return func(o *streamOptions) {
+		o.buffer = n
+	}
+}
+
+// Stream lookup in progress, slot receives its result in ordered mode
+type streamJob struct {
+	input string
+	slot  chan Result
+}
+
+// Get VIES data for every number read from in using a pool of concurrent
+// lookups. Numbers failing the local check are reported without asking the
+// service. The input is read only as fast as results are consumed, and the
+// returned channel is closed once in is closed and all results are emitted,
+// or after ctx is cancelled and running lookups have finished.
+func (c *VIESClient) VerifyStream(ctx context.Context, in <-chan string, opts ...StreamOption) <-chan Result {
+	o := streamOptions{concurrency: defaultConcurrency}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.concurrency < 1 {
+		o.concurrency = 1
+	}
+	if o.buffer < 0 {
+		o.buffer = 0
+	}
+
+	out := make(chan Result, o.buffer)
+	jobs := make(chan streamJob)
+
+	// results wait here in input order, the capacity bounds lookups ahead of the reader
+	var pending chan chan Result
+	if o.ordered {
+		pending = make(chan chan Result, o.concurrency)
+	}
+
+	// send result to the reader unless the stream was cancelled
+	emit := func(r Result) bool {
+		select {
+		case out <- r:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	var workers sync.WaitGroup
+	for i := 0; i < o.concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for job := range jobs {
+				r := c.verify(ctx, job.input)
+				if job.slot != nil {
+					job.slot <- r
+				} else if !emit(r) {
+					return
+				}
+			}
+		}()
+	}
+
+	// dispatch input to the workers
+	go func() {
+		defer close(jobs)
+		if pending != nil {
+			defer close(pending)
+		}
+
+		for {
+			var input string
+			select {
+			case <-ctx.Done():
+				return
+			case s, ok := <-in:
+				if !ok {
+					return
+				}
+				input = s
+			}
+
+			job := streamJob{input: input}
+			if pending != nil {
+				job.slot = make(chan Result, 1)
+				select {
+				case pending <- job.slot:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			// invalid numbers do not need a worker
+			if !c.uevat.isValid(input) {
+				r := Result{Input: input, Err: c.newError(CLI_EUVAT, "")}
+				if job.slot != nil {
+					job.slot <- r
+				} else if !emit(r) {
+					return
+				}
+				continue
+			}
+
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// collect results and close the output once every worker has stopped
+	go func() {
+		defer close(out)
+		if pending != nil {
+			for slot := range pending {
+				select {
+				case r := <-slot:
+					emit(r)
+				case <-ctx.Done():
+				}
+			}
+		}
+		workers.Wait()
+	}()
+
+	return out
+}
+
+// Get VIES data for single valid number counting requests sent for it
+func (c *VIESClient) verify(ctx context.Context, input string) Result {
+	start := time.Now()
+	number, _ := c.uevat.normalize(input)
+
+	// count requests, keeping hooks already carried by the context; requests
+	// of a background refresh finishing after the lookup are not counted
+	var attempts atomic.Int32
+	var done atomic.Bool
+	parent := ContextClientTrace(ctx)
+	trace := &ClientTrace{
+		CacheLookup: func(number string, hit bool) {
+			if parent != nil && parent.CacheLookup != nil {
+				parent.CacheLookup(number, hit)
+			}
+		},
+		RequestDone: func(info RequestInfo) {
+			if !done.Load() {
+				attempts.Add(1)
+			}
+			if parent != nil && parent.RequestDone != nil {
+				parent.RequestDone(info)
+			}
+		},
+	}
+
+	info := &lookupInfo{}
+	ctx = context.WithValue(WithClientTrace(ctx, trace), lookupInfoKey{}, info)
+	data, e := c.GetVIESDataContext(ctx, number)
+	done.Store(true)
+
+	res := Result{
+		Input:    input,
+		Number:   number,
+		Data:     data,
+		Err:      e,
+		Latency:  time.Since(start),
+		Attempts: int(attempts.Load()),
+		Shared:   info.shared,
+	}
+	if data != nil && data.Stale && !data.Degraded {
+		// served at once, any request belongs to the background refresh
+		res.Attempts = 0
+	}
+	return res
+}
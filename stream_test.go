@@ -0,0 +1,188 @@
+package viesapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Start server answering lookups of DE numbers, earlier numbers more slowly,
+// and recording the highest number of concurrent requests
+func streamServer(t *testing.T, active, peak *atomic.Int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+
+		number := strings.TrimPrefix(r.URL.Path, "/get/vies/euvat/DE")
+		delay := time.Duration(9-int(number[len(number)-1]-'0')) * 2 * time.Millisecond
+		time.Sleep(delay)
+		w.Write([]byte(`<result><vies><uid>` + number + `</uid><countryCode>DE</countryCode><vatNumber>` + number + `</vatNumber><valid>true</valid><date>2024-01-15</date></vies></result>`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Send numbers to a new channel and close it
+func feed(numbers []string) <-chan string {
+	in := make(chan string)
+	go func() {
+		defer close(in)
+		for _, n := range numbers {
+			in <- n
+		}
+	}()
+	return in
+}
+
+func TestVerifyStream(t *testing.T) {
+	var active, peak atomic.Int32
+	server := streamServer(t, &active, &peak)
+	c := NewVIESClient("test_id", "test_key")
+	c.SetUrl(server.URL)
+
+	var numbers []string
+	for i := 0; i < 10; i++ {
+		numbers = append(numbers, fmt.Sprintf("DE 12345678%d", i))
+	}
+	numbers = append(numbers, "XX123")
+
+	tests := []struct {
+		name    string
+		opts    []StreamOption
+		ordered bool
+	}{
+		{"unordered", []StreamOption{WithConcurrency(3)}, false},
+		{"ordered", []StreamOption{WithConcurrency(3), WithOrderedResults()}, true},
+		{"buffered", []StreamOption{WithConcurrency(3), WithOrderedResults(), WithResultBuffer(5)}, true},
+	}
+	for _, tt := range tests {
+		peak.Store(0)
+		var inputs []string
+		for r := range c.VerifyStream(context.Background(), feed(numbers), tt.opts...) {
+			inputs = append(inputs, r.Input)
+			if r.Input == "XX123" {
+				if r.Err == nil || r.Err.Code != CLI_EUVAT || r.Attempts != 0 || r.Number != "" {
+					t.Errorf("%s: invalid input result = %+v, want CLI_EUVAT", tt.name, r)
+				}
+				continue
+			}
+			want := strings.ReplaceAll(r.Input, " ", "")
+			if r.Err != nil || r.Number != want || r.Data.VATNumber != want[2:] || r.Attempts != 1 || r.Latency <= 0 {
+				t.Errorf("%s: result = %+v, want data of %s", tt.name, r, want)
+			}
+		}
+
+		if !tt.ordered {
+			sort.Strings(inputs)
+		}
+		if strings.Join(inputs, ",") != strings.Join(numbers, ",") {
+			t.Errorf("%s: inputs = %v, want %v", tt.name, inputs, numbers)
+		}
+		if p := peak.Load(); p > 3 {
+			t.Errorf("%s: peak concurrency = %d, want at most 3", tt.name, p)
+		}
+	}
+}
+
+func TestVerifyStreamBackPressure(t *testing.T) {
+	var active, peak atomic.Int32
+	server := streamServer(t, &active, &peak)
+	c := NewVIESClient("test_id", "test_key")
+	c.SetUrl(server.URL)
+
+	for _, ordered := range []bool{false, true} {
+		opts := []StreamOption{WithConcurrency(2), WithResultBuffer(1)}
+		if ordered {
+			opts = append(opts, WithOrderedResults())
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan string)
+		var sent atomic.Int32
+		go func() {
+			for i := 0; ; i++ {
+				select {
+				case in <- fmt.Sprintf("DE12345678%d", i%10):
+					sent.Add(1)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		out := c.VerifyStream(ctx, in, opts...)
+		time.Sleep(100 * time.Millisecond)
+
+		// buffered result, result waiting for the buffer, two lookups and the next number
+		if n := sent.Load(); n > 5 {
+			t.Errorf("ordered %v: read %d numbers without consumer, want at most 5", ordered, n)
+		}
+
+		<-out
+		cancel()
+		for range out {
+		}
+	}
+}
+
+func TestVerifyStreamDuplicates(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte(`<result><vies><uid>uid</uid><countryCode>DE</countryCode><vatNumber>123456789</vatNumber><valid>true</valid><date>2024-01-15</date></vies></result>`))
+	}))
+	defer server.Close()
+	c := NewVIESClient("test_id", "test_key")
+	c.SetUrl(server.URL)
+
+	numbers := []string{"DE123456789", "DE123456789", "DE123456789"}
+	var sent, shared int
+	for r := range c.VerifyStream(context.Background(), feed(numbers), WithConcurrency(3)) {
+		if r.Err != nil {
+			t.Fatalf("result = %+v, want data", r)
+		}
+		switch {
+		case r.Shared && r.Attempts == 0:
+			shared++
+		case !r.Shared && r.Attempts == 1:
+			sent++
+		default:
+			t.Errorf("result shared %v with %d attempts", r.Shared, r.Attempts)
+		}
+	}
+	if hits.Load() != 1 || sent != 1 || shared != 2 {
+		t.Errorf("hits = %d, sent = %d, shared = %d; want 1, 1, 2", hits.Load(), sent, shared)
+	}
+}
+
+func TestVerifyStreamStale(t *testing.T) {
+	var hits, code atomic.Int32
+	server := staleServer(t, &hits, &code)
+
+	cache := NewMemoryCache(0)
+	c := NewVIESClient("test_id", "test_key",
+		WithCache(cache, time.Minute),
+		WithStalePolicy(StalePolicy{Revalidate: time.Hour}),
+	)
+	c.SetUrl(server.URL)
+	cache.Set("PL7272445205", &VIESData{UID: "old-uid"}, time.Now().Add(-10*time.Minute))
+
+	for r := range c.VerifyStream(context.Background(), feed([]string{"PL7272445205"})) {
+		if r.Data == nil || !r.Data.Stale || r.Attempts != 0 {
+			t.Errorf("result = %+v, attempts %d; want stale data without attempts", r.Data, r.Attempts)
+		}
+	}
+	c.refresh.Wait()
+	if hits.Load() != 1 {
+		t.Errorf("hits = %d, want background refresh", hits.Load())
+	}
+}
@@ -0,0 +1,68 @@
+package viesapi
+
+import (
+	"context"
+	"time"
+)
+
+// ClientTrace is a set of hooks run at various stages of a VIESClient call,
+// in the manner of net/http/httptrace. Any hook may be nil.
+type ClientTrace struct {
+	// CacheLookup is called after the cache was consulted for a normalized number
+	CacheLookup func(number string, hit bool)
+	// RequestDone is called after each HTTP request to the service
+	RequestDone func(info RequestInfo)
+}
+
+// RequestInfo describes single HTTP request to the service
+type RequestInfo struct {
+	URL      string
+	Attempt  int
+	Duration time.Duration
+	Status   int // zero if no response was received
+	Size     int
+	Code     int // error code reported by the service or the client
+	Err      error
+}
+
+type clientTraceKey struct{}
+
+// Return context carrying specified trace hooks
+func WithClientTrace(ctx context.Context, trace *ClientTrace) context.Context {
+	return context.WithValue(ctx, clientTraceKey{}, trace)
+}
+
+// Get trace hooks carried by the context or nil
+func ContextClientTrace(ctx context.Context) *ClientTrace {
+	trace, _ := ctx.Value(clientTraceKey{}).(*ClientTrace)
+	return trace
+}
+
+// Get trace hooks of the client combined with those carried by the context
+func (c *VIESClient) trace(ctx context.Context) *ClientTrace {
+	t := ContextClientTrace(ctx)
+	switch {
+	case t == nil:
+		return c.hooks
+	case c.hooks == nil:
+		return t
+	}
+
+	hooks := []*ClientTrace{c.hooks, t}
+	return &ClientTrace{
+		CacheLookup: func(number string, hit bool) {
+			for _, h := range hooks {
+				if h.CacheLookup != nil {
+					h.CacheLookup(number, hit)
+				}
+			}
+		},
+		RequestDone: func(info RequestInfo) {
+			for _, h := range hooks {
+				if h.RequestDone != nil {
+					h.RequestDone(info)
+				}
+			}
+		},
+	}
+}
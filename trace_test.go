@@ -0,0 +1,49 @@
+package viesapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientTrace(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<result><vies><uid>test-uid</uid><countryCode>PL</countryCode><valid>true</valid><vatNumber>7272445205</vatNumber><date>2024-01-15</date></vies><error><code>0</code></error></result>`))
+	}))
+	defer server.Close()
+
+	c := NewVIESClient("test_id", "test_key", WithCache(NewMemoryCache(0), time.Minute))
+	c.SetUrl(server.URL)
+
+	var hits []bool
+	var requests []RequestInfo
+	ctx := WithClientTrace(context.Background(), &ClientTrace{
+		CacheLookup: func(number string, hit bool) {
+			if number != "PL7272445205" {
+				t.Errorf("CacheLookup number = %s, want PL7272445205", number)
+			}
+			hits = append(hits, hit)
+		},
+		RequestDone: func(info RequestInfo) {
+			requests = append(requests, info)
+		},
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetVIESDataContext(ctx, "PL7272445205"); err != nil {
+			t.Fatalf("GetVIESDataContext returned error: %v", err)
+		}
+	}
+
+	if len(hits) != 2 || hits[0] || !hits[1] {
+		t.Errorf("cache lookups = %v, want [false true]", hits)
+	}
+	if len(requests) != 1 {
+		t.Fatalf("requests = %d, want 1", len(requests))
+	}
+	if info := requests[0]; info.Status != http.StatusOK || info.Attempt != 1 || info.Code != 0 || info.Size == 0 {
+		t.Errorf("request info = %+v", info)
+	}
+}
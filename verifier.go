@@ -0,0 +1,11 @@
+package viesapi
+
+import "context"
+
+// Verifier looks up VIES data of EU VAT numbers, implemented by VIESClient
+// and by alternative backends such as the ecvies package
+type Verifier interface {
+	GetVIESDataContext(ctx context.Context, euvat string) (*VIESData, *ViesError)
+}
+
+var _ Verifier = (*VIESClient)(nil)
@@ -1,7 +1,12 @@
 package viesapi
 
 import (
+	"context"
 	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
 	"time"
 )
 
@@ -18,6 +23,8 @@ type VIESData struct {
 	ID                string `json:"id" xml:"id"`
 	Date              string `json:"date" xml:"date"`
 	Source            string `json:"source" xml:"source"`
+	Stale             bool   `json:"stale,omitempty" xml:"stale,omitempty"`       // served from cache or history past its ttl
+	Degraded          bool   `json:"degraded,omitempty" xml:"degraded,omitempty"` // served because the lookup failed
 }
 
 type AccountStatus struct {
@@ -42,54 +49,221 @@ type AccountStatus struct {
 	TotalCount        int        `json:"total_count" xml:"totalCount"`
 }
 
-// Create new VIESClient instance with specified id and key or use test credentials
-func NewVIESClient(id, key string) *VIESClient {
+// Option configures optional VIESClient behaviour
+type Option func(*VIESClient)
 
-	url := production_url
-	if id == "" || key == "" {
-		id = test_id
-		key = test_key
-		url = test_url
+// Use specified HTTP client for requests to the service
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *VIESClient) {
+		c.client = client
 	}
-	return &VIESClient{
-		id:    id,
-		key:   key,
-		url:   url,
-		err:   Error{},
-		nip:   NIP{},
-		uevat: EUVAT{},
+}
+
+// Keep successful VIES data results in cache and reuse them for ttl
+func WithCache(cache Cache, ttl time.Duration) Option {
+	return func(c *VIESClient) {
+		c.cache = cache
+		c.ttl = ttl
+	}
+}
+
+// Send at most one request to the service per specified interval
+func WithRateLimit(interval time.Duration) Option {
+	return func(c *VIESClient) {
+		c.limiter = &limiter{interval: interval}
+	}
+}
+
+// Use specified clock instead of time.Now for signing and cache expiry
+func WithClock(clock func() time.Time) Option {
+	return func(c *VIESClient) {
+		c.clock = clock
+	}
+}
+
+// Use specified generator of authorization nonces instead of random bytes
+func WithNonce(nonce func() (string, error)) Option {
+	return func(c *VIESClient) {
+		c.nonce = nonce
+	}
+}
+
+// Log every request to the service with specified logger
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *VIESClient) {
+		c.logger = logger
+	}
+}
+
+// Log VAT numbers in full instead of masking them
+func WithUnmaskedLogs() Option {
+	return func(c *VIESClient) {
+		c.unmask = true
+	}
+}
+
+// Run specified trace hooks for every call, in addition to hooks carried by the context
+func WithTrace(trace *ClientTrace) Option {
+	return func(c *VIESClient) {
+		c.hooks = trace
+	}
+}
+
+// Record every VIES data lookup in specified audit sink
+func WithAudit(sink AuditSink) Option {
+	return func(c *VIESClient) {
+		c.auditor = sink
+	}
+}
+
+// Save every VIES data result fetched from the service in specified history store
+func WithHistory(store HistoryStore) Option {
+	return func(c *VIESClient) {
+		c.history = store
+	}
+}
+
+// Read at most n bytes of every response, larger responses fail with
+// CLI_RESPONSE. Zero or less keeps the default limit of 1 MiB.
+func WithMaxResponseSize(n int64) Option {
+	return func(c *VIESClient) {
+		if n <= 0 {
+			n = defaultMaxResponseSize
+		}
+		c.maxSize = n
+	}
+}
+
+// Request responses in specified format, ResponseXML by default
+func WithResponseFormat(format ResponseFormat) Option {
+	return func(c *VIESClient) {
+		c.format = format
+	}
+}
+
+// Get credentials for every call from specified provider instead of the
+// identifier and key given to NewVIESClient
+func WithCredentials(provider CredentialsProvider) Option {
+	return func(c *VIESClient) {
+		c.provider = provider
 	}
 }
 
+// Keep accepting the previous credentials for specified period after the
+// provider rotated them, retrying with them if the service rejects the new key
+func WithRotationGrace(grace time.Duration) Option {
+	return func(c *VIESClient) {
+		c.grace = grace
+	}
+}
+
+// Fail fast lookups for member states whose circuit is open in specified breaker
+func WithCircuitBreaker(breaker *CircuitBreaker) Option {
+	return func(c *VIESClient) {
+		c.breaker = breaker
+	}
+}
+
+// Serve cached or historical results older than the cache ttl according to specified policy
+func WithStalePolicy(policy StalePolicy) Option {
+	return func(c *VIESClient) {
+		c.stale = policy
+	}
+}
+
+// Create new VIESClient instance with specified id and key or use test
+// credentials if either is empty, unless credentials come from WithCredentials
+// provider or the environment is selected explicitly
+func NewVIESClient(id, key string, opts ...Option) *VIESClient {
+	c := newClient(opts)
+	if c.provider == nil && !c.envSet && (id == "" || key == "") {
+		id, key = "", ""
+		c.env = Test
+	}
+	c.setup(id, key)
+	return c
+}
+
+// Create client with default settings and apply options
+func newClient(opts []Option) *VIESClient {
+	c := &VIESClient{
+		err:     Error{},
+		nip:     NIP{},
+		uevat:   EUVAT{},
+		client:  &http.Client{},
+		clock:   time.Now,
+		maxSize: defaultMaxResponseSize,
+		format:  ResponseXML,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
 // Get current account status
 // GetAccountStatus returns account status or nil in case of error
 func (c *VIESClient) GetAccountStatus() (*AccountStatus, *ViesError) {
-	status := c.getAccountStatus()
-	if status != nil {
-		return status, nil
-	}
-	return nil, &ViesError{c.errcode, c.errmsg}
+	return c.GetAccountStatusContext(context.Background())
+}
+
+// Get current account status using specified context
+// GetAccountStatusContext returns account status or nil in case of error
+func (c *VIESClient) GetAccountStatusContext(ctx context.Context) (*AccountStatus, *ViesError) {
+	return c.getAccountStatus(ctx)
 }
 
 // Get VIES data for specified number from EU VIES system
 // GetVIESData returns VIES data or nil in case of error
 func (c *VIESClient) GetVIESData(euvat string) (*VIESData, *ViesError) {
-	data := c.getData(euvat)
-	if data != nil {
-		return data, nil
+	return c.GetVIESDataContext(context.Background(), euvat)
+}
+
+// Get VIES data for specified number from EU VIES system using specified context
+// GetVIESDataContext returns VIES data or nil in case of error
+func (c *VIESClient) GetVIESDataContext(ctx context.Context, euvat string) (*VIESData, *ViesError) {
+	return c.getData(ctx, euvat)
+}
+
+// Get VIES data for number stored separately from its country code, the number
+// may repeat the country prefix and Greece may be given by its ISO code GR
+// GetVIESDataFor returns VIES data or nil in case of error
+func (c *VIESClient) GetVIESDataFor(countryCode, number string) (*VIESData, *ViesError) {
+	return c.GetVIESDataForContext(context.Background(), countryCode, number)
+}
+
+// Get VIES data for number stored separately from its country code using specified context
+// GetVIESDataForContext returns VIES data or nil in case of error
+func (c *VIESClient) GetVIESDataForContext(ctx context.Context, countryCode, number string) (*VIESData, *ViesError) {
+	euvat, ok := c.uevat.join(countryCode, number)
+	if !ok {
+		e := c.newError(CLI_COUNTRY, "")
+		c.last(e)
+		c.audit(ctx, countryCode+number, false, nil, e)
+		return nil, e
 	}
-	return nil, &ViesError{c.errcode, c.errmsg}
+	return c.getData(ctx, euvat)
 }
 
 // Get last error message
 func (c *VIESClient) GetLastError() (int, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.errcode, c.errmsg
+}
 
+// Get difference between the service clock and the local clock measured from
+// the last response, positive if the local clock is behind
+func (c *VIESClient) ClockSkew() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.skew
 }
 
-// Set non default service URL
+// Set non default service URL, switching the client to the Custom environment
 func (c *VIESClient) SetUrl(url string) {
 	c.url = url
+	c.env = Custom
 }
 
 // Return last code and description of error
@@ -98,14 +272,31 @@ func (e *ViesError) Error() string {
 	return string(b)
 }
 
-// Return account status as string
+// Return underlying error such as transport failure or context cancellation
+func (e *ViesError) Unwrap() error {
+	return e.err
+}
+
+// Report TEST_MODE errors of the service as ErrTestMode
+func (e *ViesError) Is(target error) bool {
+	return target == ErrTestMode && e.Code == TEST_MODE
+}
+
+// Return account status as JSON string
 func (a *AccountStatus) String() string {
-	b, _ := json.Marshal(a)
-	return string(b)
+	return encodeString(a.Encode)
 }
 
-// Return VIES data as string
+// Return VIES data as JSON string
 func (v *VIESData) String() string {
-	b, _ := json.Marshal(v)
-	return string(b)
+	return encodeString(v.Encode)
+}
+
+// Encode value as compact JSON string, reporting failure in fmt style
+func encodeString(encode func(io.Writer, Format) error) string {
+	var b strings.Builder
+	if err := encode(&b, FormatJSON); err != nil {
+		return "%!(ERROR=" + err.Error() + ")"
+	}
+	return strings.TrimSuffix(b.String(), "\n")
 }
@@ -108,6 +108,7 @@ func TestVIESClientGetVIESData(t *testing.T) {
 		<vatNumber>1234567890</vatNumber>
 		<valid>true</valid>
 		<traderName>Test Company</traderName>
+		<date>2024-01-15</date>
 	</vies>
 	<error>
 		<code>0</code>
@@ -147,6 +148,58 @@ func TestVIESClientGetVIESDataError(t *testing.T) {
 	}
 }
 
+func TestVIESClientGetVIESDataFor(t *testing.T) {
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Write([]byte(`<result><vies><uid>test-uid</uid><valid>true</valid><countryCode>PL</countryCode><vatNumber>7272445205</vatNumber><date>2024-01-15</date></vies><error><code>0</code></error></result>`))
+	}))
+	defer server.Close()
+
+	c := NewVIESClient("test_id", "test_key")
+	c.SetUrl(server.URL)
+
+	tests := []struct {
+		country string
+		number  string
+		want    string
+		code    int
+	}{
+		{"PL", "7272445205", "PL7272445205", 0},
+		{"pl", "PL 727-244-52-05", "PL7272445205", 0},
+		{"GR", "123456789", "EL123456789", 0},
+		{"GR", "GR123456789", "EL123456789", 0},
+		{"EL", "EL123456789", "EL123456789", 0},
+		{"FR", "FR123456789", "FRFR123456789", 0},
+		{"FR", "FRFR123456789", "FRFR123456789", 0},
+		{"FR", "FRAB123456789", "FRAB123456789", 0},
+		{"FR", "AB123456789", "FRAB123456789", 0},
+		{"PL", "7272445206", "", CLI_EUVAT},
+		{"US", "123456789", "", CLI_COUNTRY},
+		{"GB", "GB123456789", "", CLI_COUNTRY},
+		{"", "PL7272445205", "", CLI_COUNTRY},
+	}
+
+	for _, tt := range tests {
+		path = ""
+		data, err := c.GetVIESDataFor(tt.country, tt.number)
+		code := 0
+		if err != nil {
+			code = err.Code
+		}
+		if code != tt.code {
+			t.Errorf("GetVIESDataFor(%q, %q) error = %v, want code %d", tt.country, tt.number, err, tt.code)
+			continue
+		}
+		if tt.code == 0 && (data == nil || path != "/get/vies/euvat/"+tt.want) {
+			t.Errorf("GetVIESDataFor(%q, %q) requested %s, want %s", tt.country, tt.number, path, tt.want)
+		}
+		if last, _ := c.GetLastError(); last != tt.code {
+			t.Errorf("GetVIESDataFor(%q, %q) last error = %d, want %d", tt.country, tt.number, last, tt.code)
+		}
+	}
+}
+
 func TestGetLastError(t *testing.T) {
 	c := NewVIESClient("", "")
 	c.set(CLI_NIP, "test error")
@@ -237,3 +290,25 @@ func TestAccountStatusNilValidTo(t *testing.T) {
 		t.Errorf("String() with nil ValidTo returned invalid JSON: %v", err)
 	}
 }
+
+func TestNormalizeEUVAT(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		ok    bool
+	}{
+		{"PL 727-244-52-05", "PL7272445205", true},
+		{"DE123456789", "DE123456789", true},
+		{"PL7272445206", "", false},
+		{"US123456789", "", false},
+		{"PL7272445205/../x", "", false},
+		{"DE123456789</urn:vatNumber><x>", "", false},
+		{"xDE123456789", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := NormalizeEUVAT(tt.input)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("NormalizeEUVAT(%s) = %s, %v; want %s, %v", tt.input, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -1,75 +1,117 @@
 package viesapi
 
 import (
-	"crypto/hmac"
-	"crypto/rand"
-	"crypto/sha256"
-	"encoding/base64"
-	"encoding/hex"
+	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 )
 
 type viesData struct {
-	XMLName xml.Name  `xml:"result"`
-	VIES    VIESData  `xml:"vies"`
-	Error   ViesError `xml:"error"`
+	XMLName xml.Name    `json:"-" xml:"result"`
+	VIES    *viesRecord `json:"vies" xml:"vies"`
+	Error   ViesError   `json:"error" xml:"error"`
+}
+
+// Required elements are pointers so that their absence can be detected
+type viesRecord struct {
+	UID               *string `json:"uid" xml:"uid"`
+	CountryCode       *string `json:"country_code" xml:"countryCode"`
+	VATNumber         *string `json:"vat_number" xml:"vatNumber"`
+	Valid             *bool   `json:"valid" xml:"valid"`
+	TraderName        string  `json:"trader_name" xml:"traderName"`
+	TraderCompanyType string  `json:"trader_company_type" xml:"traderCompanyType"`
+	TraderAddress     string  `json:"trader_address" xml:"traderAddress"`
+	ID                string  `json:"id" xml:"id"`
+	Date              *string `json:"date" xml:"date"`
+	Source            string  `json:"source" xml:"source"`
+	Stale             bool    `json:"stale" xml:"stale"`
+	Degraded          bool    `json:"degraded" xml:"degraded"`
 }
 
 type viesAccountStatus struct {
-	XMLName xml.Name    `xml:"result"`
-	Account viesAccount `xml:"account"`
-	Error   ViesError   `xml:"error"`
+	XMLName xml.Name     `json:"-" xml:"result"`
+	Account *viesAccount `json:"account" xml:"account"`
+	Error   ViesError    `json:"error" xml:"error"`
 }
 
 type viesAccount struct {
-	UID         string          `xml:"uid"`
-	Type        string          `xml:"type"`
-	ValidTo     string          `xml:"validTo"`
-	BillingPlan viesBillingPlan `xml:"billingPlan"`
+	UID         string          `json:"uid" xml:"uid"`
+	Type        string          `json:"type" xml:"type"`
+	ValidTo     string          `json:"valid_to" xml:"validTo"`
+	BillingPlan viesBillingPlan `json:"billing_plan" xml:"billingPlan"`
 	Requests    struct {
-		VIESDataCount int `xml:"viesData"`
-		TotalCount    int `xml:"total"`
-	} `xml:"requests"`
+		VIESDataCount int `json:"vies_data" xml:"viesData"`
+		TotalCount    int `json:"total" xml:"total"`
+	} `json:"requests" xml:"requests"`
 }
 
 type viesBillingPlan struct {
-	Name              string  `xml:"name"`
-	SubscriptionPrice float64 `xml:"subscriptionPrice"`
-	ItemPrice         float64 `xml:"itemPrice"`
-	ItemPriceStatus   float64 `xml:"itemPriceCheckStatus"`
-	Limit             int     `xml:"limit"`
-	RequestDelay      int     `xml:"requestDelay"`
-	DomainLimit       int     `xml:"domainLimit"`
-	OverPlanAllowed   bool    `xml:"overplanAllowed"`
-	ExcelAddIn        bool    `xml:"excelAddin"`
-	App               bool    `xml:"app"`
-	CLI               bool    `xml:"cli"`
-	Stats             bool    `xml:"stats"`
-	Monitor           bool    `xml:"monitor"`
-	FuncGetVIESData   bool    `xml:"funcGetVIESData"`
+	Name              string  `json:"name" xml:"name"`
+	SubscriptionPrice float64 `json:"subscription_price" xml:"subscriptionPrice"`
+	ItemPrice         float64 `json:"item_price" xml:"itemPrice"`
+	ItemPriceStatus   float64 `json:"item_price_check_status" xml:"itemPriceCheckStatus"`
+	Limit             int     `json:"limit" xml:"limit"`
+	RequestDelay      int     `json:"request_delay" xml:"requestDelay"`
+	DomainLimit       int     `json:"domain_limit" xml:"domainLimit"`
+	OverPlanAllowed   bool    `json:"overplan_allowed" xml:"overplanAllowed"`
+	ExcelAddIn        bool    `json:"excel_addin" xml:"excelAddin"`
+	App               bool    `json:"app" xml:"app"`
+	CLI               bool    `json:"cli" xml:"cli"`
+	Stats             bool    `json:"stats" xml:"stats"`
+	Monitor           bool    `json:"monitor" xml:"monitor"`
+	FuncGetVIESData   bool    `json:"func_get_vies_data" xml:"funcGetVIESData"`
 }
 
 type ViesError struct {
 	Code        int    `json:"code" xml:"code"`
 	Description string `json:"description" xml:"description"`
+	err         error
 }
 
 type VIESClient struct {
-	id      string
-	key     string
-	url     string
-	errcode int
-	errmsg  string
-	err     Error
-	uevat   EUVAT
-	nip     NIP
+	url      string
+	errcode  int
+	errmsg   string
+	err      Error
+	uevat    EUVAT
+	nip      NIP
+	mu       sync.Mutex
+	client   *http.Client
+	cache    Cache
+	ttl      time.Duration
+	limiter  *limiter
+	flight   flight
+	clock    func() time.Time
+	skew     time.Duration
+	offset   time.Duration
+	nonce    func() (string, error)
+	provider CredentialsProvider
+	creds    Credentials
+	prev     Credentials
+	rotated  time.Time
+	grace    time.Duration
+	logger   *slog.Logger
+	unmask   bool
+	hooks    *ClientTrace
+	auditor  AuditSink
+	history  HistoryStore
+	maxSize  int64
+	format   ResponseFormat
+	env      Environment
+	envSet   bool
+	noTest   bool
+	breaker  *CircuitBreaker
+	stale    StalePolicy
+	refresh  sync.WaitGroup
 }
 
 const (
@@ -86,70 +128,118 @@ const (
 )
 
 // Get VIES data for specified number
-func (c *VIESClient) getData(euvat string) *VIESData {
+func (c *VIESClient) getData(ctx context.Context, euvat string) (vies *VIESData, e *ViesError) {
 
-	// clear error
-	c.clear()
+	// store error info and audit record on return
+	number, cached := euvat, false
+	defer func() {
+		c.last(e)
+		c.audit(ctx, number, cached, vies, e)
+	}()
 
 	// validate number and construct path
-	suffix, ok := c.getPathSuffix(numberEUVAT, euvat)
-	if !ok {
-		return nil
+	suffix, e := c.getPathSuffix(numberEUVAT, euvat)
+	if e != nil {
+		return nil, e
 	}
 
-	//prepare url
-	url := c.url + "/get/vies/" + suffix
+	// check cache
+	key := strings.TrimPrefix(suffix, "euvat/")
+	number = key
+	if c.cache != nil {
+		vies = c.cached(key)
+		if trace := c.trace(ctx); trace != nil && trace.CacheLookup != nil {
+			trace.CacheLookup(key, vies != nil)
+		}
+		if vies != nil {
+			cached = true
+			return vies, nil
+		}
+	}
 
-	// send request
-	res := c.get(url)
-	if res == nil {
-		c.set(CLI_CONNECT, "")
-		return nil
+	// serve the last known result while refreshing it in the background
+	if c.stale != (StalePolicy{}) {
+		if vies = c.revalidate(ctx, key); vies != nil {
+			cached = true
+			return vies, nil
+		}
 	}
 
-	// parse response
+	// share the request with concurrent lookups of the same number
+	vies, e, shared := c.flight.do(ctx, key, func(ctx context.Context) (*VIESData, *ViesError) {
+		return c.lookup(ctx, key)
+	})
+	if info := contextLookupInfo(ctx); info != nil {
+		info.shared = shared
+	}
+	if e != nil && ctx.Err() == nil {
+		if last := c.degraded(ctx, key, e); last != nil {
+			cached = true
+			return last, nil
+		}
+	}
+	return vies, e
+}
+
+// Fetch VIES data for specified normalized number unless its member state circuit is open
+func (c *VIESClient) lookup(ctx context.Context, key string) (*VIESData, *ViesError) {
+	country := key[:2]
+	if !c.breaker.allow(country) {
+		return nil, c.wrapError(CLI_CIRCUIT_OPEN, ErrCircuitOpen)
+	}
+	data, e := c.fetchData(ctx, key)
+	c.breaker.record(country, e)
+	return data, e
+}
+
+// Fetch VIES data for specified normalized number from the service
+func (c *VIESClient) fetchData(ctx context.Context, euvat string) (*VIESData, *ViesError) {
+
+	//prepare url
+	url := c.url + "/get/vies/euvat/" + euvat
+
+	// send request and decode response
 	var data viesData
-	err := xml.Unmarshal(res, &data)
-	if err != nil {
-		c.set(CLI_RESPONSE, "")
-		return nil
+	if e := c.get(ctx, url, &data); e != nil {
+		return nil, e
 	}
 
 	if data.Error.Code != 0 {
-		c.set(data.Error.Code, data.Error.Description)
-		return nil
+		return nil, c.newError(data.Error.Code, data.Error.Description)
+	}
+
+	vies, err := data.VIES.data()
+	if err != nil {
+		return nil, c.wrapError(CLI_RESPONSE, err)
 	}
 
-	return &data.VIES
+	if c.cache != nil {
+		c.cache.Set(euvat, vies, c.clock())
+	}
+	c.remember(ctx, euvat, vies)
+	return vies, nil
 }
 
 // Get user account's status
-func (c *VIESClient) getAccountStatus() *AccountStatus {
+func (c *VIESClient) getAccountStatus(ctx context.Context) (status *AccountStatus, e *ViesError) {
 
-	// clear error
-	c.clear()
+	// store error info on return
+	defer func() { c.last(e) }()
 
 	//prepare url
 	url := c.url + "/check/account/status"
 
-	// send request
-	res := c.get(url)
-	if res == nil {
-		c.set(CLI_CONNECT, "")
-		return nil
-	}
-
-	// parse response
+	// send request and decode response
 	var data viesAccountStatus
-	err := xml.Unmarshal(res, &data)
-	if err != nil {
-		c.set(CLI_RESPONSE, "")
-		return nil
+	if e := c.get(ctx, url, &data); e != nil {
+		return nil, e
 	}
 
 	if data.Error.Code != 0 {
-		c.set(data.Error.Code, data.Error.Description)
-		return nil
+		return nil, c.newError(data.Error.Code, data.Error.Description)
+	}
+	if data.Account == nil {
+		return nil, c.wrapError(CLI_RESPONSE, fmt.Errorf("%w: account", ErrMissingElement))
 	}
 
 	return &AccountStatus{
@@ -172,38 +262,20 @@ func (c *VIESClient) getAccountStatus() *AccountStatus {
 		FuncGetVIESData:   data.Account.BillingPlan.FuncGetVIESData,
 		VIESDataCount:     data.Account.Requests.VIESDataCount,
 		TotalCount:        data.Account.Requests.TotalCount,
-	}
+	}, nil
 }
 
 // Prepare authorization header content
-func (c *VIESClient) auth(method, urlstr string) (string, bool) {
-
-	//parse url
-	url, _ := url.Parse(urlstr)
-	if url == nil {
-		c.set(CLI_INPUT, "")
-		return "", false
-	}
-	host := url.Host
-	port := "80"
-	if url.Scheme == "https" {
-		port = "443"
-	}
-
-	i := strings.LastIndexByte(host, ':')
-	if i > 0 {
-		host = host[:i]
-		port = url.Host[i+1:]
+func (c *VIESClient) auth(creds Credentials, method, urlstr string) (string, *ViesError) {
+	header, err := NewSigner(creds.ID, creds.Key, c.now, c.nonce).Sign(method, urlstr)
+	if err != nil {
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			return "", c.newError(CLI_INPUT, "")
+		}
+		return "", c.newError(CLI_EXCEPTION, err.Error())
 	}
-
-	// prepare auth header value
-	nonce := c.randomHex(4)
-	ts := time.Now().Unix()
-	s := fmt.Sprintf("%d\n%s\n%s\n%s\n%s\n%s\n\n", ts, nonce, method, url.Path, host, port)
-
-	mac := c.getMac(s)
-
-	return fmt.Sprintf(`MAC id="%s", ts="%d", nonce="%s", mac="%s"`, c.id, ts, nonce, mac), true
+	return header, nil
 }
 
 // Prepare user agent information header content
@@ -213,79 +285,238 @@ func (c *VIESClient) userAgent() string {
 
 // Clear error info
 func (c *VIESClient) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.errcode = 0
 	c.errmsg = ""
 }
 
 // Set error info
 func (c *VIESClient) set(code int, msg string) {
-	c.errcode = code
-	if msg != "" {
-		c.errmsg = msg
-	} else {
-		c.errmsg = c.err.message(code)
+	e := c.newError(code, msg)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errcode = e.Code
+	c.errmsg = e.Description
+}
+
+// Store error info of the last call
+func (c *VIESClient) last(e *ViesError) {
+	if e == nil {
+		c.clear()
+		return
+	}
+	c.set(e.Code, e.Description)
+}
+
+// Create error info for specified code
+func (c *VIESClient) newError(code int, msg string) *ViesError {
+	if msg == "" {
+		msg = c.err.message(code)
 	}
+	return &ViesError{Code: code, Description: msg}
 }
 
-// Get result of HTTP GET request
-func (c *VIESClient) get(url string) []byte {
+// Create error info for specified code caused by err
+func (c *VIESClient) wrapError(code int, err error) *ViesError {
+	e := c.newError(code, "")
+	e.err = err
+	return e
+}
 
-	auth, ok := c.auth("GET", url)
-	if !ok {
+// Get cached VIES data for specified normalized number if still fresh
+func (c *VIESClient) cached(key string) *VIESData {
+	data, at, ok := c.cache.Get(key)
+	if !ok || c.clock().Sub(at) >= c.ttl {
 		return nil
 	}
+	return data
+}
+
+// Send HTTP GET request and decode its response into v, retrying once with
+// corrected timestamp if the service rejected it because of local clock skew
+// and once with the previous credentials if it rejected rotated ones during
+// the grace period
+func (c *VIESClient) get(ctx context.Context, url string, v response) *ViesError {
 
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
+	trace := c.trace(ctx)
+
+	creds, prev, e := c.credentials(ctx)
+	if e != nil {
+		return e
+	}
+	if e := c.checkTestMode(creds); e != nil {
+		return e
+	}
+
+	corrected := false
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		status, size, e := c.send(ctx, url, creds, v)
+
+		info := RequestInfo{
+			URL:      url,
+			Attempt:  attempt,
+			Duration: time.Since(start),
+			Status:   status,
+			Size:     int(size),
+		}
+		if e != nil {
+			info.Code = e.Code
+			info.Err = e.err
+		} else {
+			info.Code = v.errorCode()
+		}
+		c.logRequest(ctx, info)
+		if trace != nil && trace.RequestDone != nil {
+			trace.RequestDone(info)
+		}
+
+		if e != nil {
+			return e
+		}
+		switch {
+		case info.Code == AUTH_TIMESTAMP && !corrected && c.correctClock():
+			corrected = true
+		case (info.Code == AUTH_MAC || info.Code == DB_AUTH_KEY_VALUE) && prev != nil:
+			creds, prev = *prev, nil
+		default:
+			return nil
+		}
+		// decode the retried response into a clean value
+		v.reset()
+	}
+}
+
+// Send HTTP GET request signed with specified credentials and decode response
+// body into v, returning the status and the number of body bytes read
+func (c *VIESClient) send(ctx context.Context, url string, creds Credentials, v response) (int, int64, *ViesError) {
+
+	// wait for the rate limiter before signing so the timestamp stays fresh
+	if err := c.limiter.wait(ctx); err != nil {
+		return 0, 0, c.wrapError(CLI_CONNECT, err)
+	}
+
+	auth, e := c.auth(creds, "GET", url)
+	if e != nil {
+		return 0, 0, e
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return nil
+		return 0, 0, c.wrapError(CLI_CONNECT, err)
 	}
 	req.Header.Set("User-Agent", c.userAgent())
+	req.Header.Set("Accept", c.accept())
 	req.Header.Set("Authorization", auth)
 
-	res, err := client.Do(req)
+	res, err := c.client.Do(req)
 	if err != nil {
-		return nil
+		return 0, 0, c.wrapError(CLI_CONNECT, err)
 	}
 	defer res.Body.Close()
 
-	body, _ := io.ReadAll(res.Body)
+	c.measureSkew(res.Header.Get("Date"))
 
-	return body
+	body := &countingReader{r: io.LimitReader(res.Body, c.maxSize+1)}
+	if !succeeded(res) {
+		// error pages are inspected as a whole for the service error code and snippet
+		b, err := io.ReadAll(body)
+		if err != nil {
+			return res.StatusCode, body.n, c.wrapError(CLI_CONNECT, err)
+		}
+		if body.n > c.maxSize {
+			return res.StatusCode, body.n, c.wrapError(CLI_RESPONSE, ErrResponseTooLarge)
+		}
+		if e := c.checkResponse(res, b); e != nil {
+			return res.StatusCode, body.n, e
+		}
+		if err := c.unmarshal(b, v); err != nil {
+			return res.StatusCode, body.n, c.wrapError(CLI_RESPONSE, err)
+		}
+		return res.StatusCode, body.n, nil
+	}
+
+	// decode while reading, then drain the rest to detect oversized responses
+	err = c.decodeFrom(body, v)
+	if _, cerr := io.Copy(io.Discard, body); cerr != nil && err == nil {
+		return res.StatusCode, body.n, c.wrapError(CLI_CONNECT, cerr)
+	}
+	if body.n > c.maxSize {
+		return res.StatusCode, body.n, c.wrapError(CLI_RESPONSE, ErrResponseTooLarge)
+	}
+	if err != nil {
+		return res.StatusCode, body.n, c.wrapError(CLI_RESPONSE, err)
+	}
+	return res.StatusCode, body.n, nil
+}
+
+// Get current time corrected by the clock offset learned from the service
+func (c *VIESClient) now() time.Time {
+	c.mu.Lock()
+	offset := c.offset
+	c.mu.Unlock()
+	return c.clock().Add(offset)
+}
+
+// Estimate clock skew from the Date header of the service response
+func (c *VIESClient) measureSkew(date string) {
+	t, err := http.ParseTime(date)
+	if err != nil {
+		return
+	}
+	skew := t.Sub(c.clock()).Round(time.Second)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.skew = skew
+}
+
+// Start using the measured clock skew for signing, returns false if it is
+// already in use and retrying would not help
+func (c *VIESClient) correctClock() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.offset == c.skew {
+		return false
+	}
+	c.offset = c.skew
+	return true
 }
 
-// Calculates HMAC256 from input string
-func (c *VIESClient) getMac(input string) string {
-	h := hmac.New(sha256.New, []byte(c.key))
-	h.Write([]byte(input))
-	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+// Get error code reported in the response body
+func (c *VIESClient) responseCode(body []byte) int {
+	var res struct {
+		Error ViesError `json:"error" xml:"error"`
+	}
+	if len(body) == 0 || c.unmarshal(body, &res) != nil {
+		return 0
+	}
+	return res.Error.Code
 }
 
 // Get path suffix for specified number type
-func (c *VIESClient) getPathSuffix(typ int, number string) (string, bool) {
-	var b bool
+func (c *VIESClient) getPathSuffix(typ int, number string) (string, *ViesError) {
 	var path string
 
 	switch typ {
 	case numberNIP:
 		if !c.nip.isValid(number) {
-			c.set(CLI_NIP, "")
-			return "", false
+			return "", c.newError(CLI_NIP, "")
 		}
-		path, b = c.nip.normalize(number)
+		path, _ = c.nip.normalize(number)
 		path = "nip/" + path
 	case numberEUVAT:
 		if !c.uevat.isValid(number) {
-			c.set(CLI_EUVAT, "")
-			return "", false
+			return "", c.newError(CLI_EUVAT, "")
 		}
-		path, b = c.uevat.normalize(number)
+		path, _ = c.uevat.normalize(number)
 		path = "euvat/" + path
 	default:
-		c.set(CLI_NUMBER, "")
-		return "", false
+		return "", c.newError(CLI_NUMBER, "")
 	}
-	return path, b
+	return path, nil
 }
 
 func (c *VIESClient) getDateTime(str string) *time.Time {
@@ -314,11 +545,3 @@ func (c *VIESClient) getDateTime(str string) *time.Time {
 	}
 	return &t
 }
-
-func (c *VIESClient) randomHex(n int) string {
-	b := make([]byte, n)
-	if _, err := rand.Read(b); err != nil {
-		return ""
-	}
-	return hex.EncodeToString(b)
-}
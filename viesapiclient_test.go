@@ -1,7 +1,9 @@
 package viesapi
 
 import (
+	"context"
 	"encoding/xml"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -10,20 +12,21 @@ import (
 )
 
 func TestGetMac(t *testing.T) {
-	c := NewVIESClient("test_id", "test_key")
 	input := "test_input"
-	mac := c.getMac(input)
+	mac := MAC("test_key", input)
 	if mac == "" {
-		t.Error("getMac returned empty string")
+		t.Error("MAC returned empty string")
 	}
-	if mac != c.getMac(input) {
-		t.Error("getMac not deterministic")
+	if mac != MAC("test_key", input) {
+		t.Error("MAC not deterministic")
 	}
 }
 
 func TestRandomHex(t *testing.T) {
-	c := NewVIESClient("", "")
-	hex := c.randomHex(4)
+	hex, err := randomHex(4)
+	if err != nil {
+		t.Fatalf("randomHex(4) returned error: %v", err)
+	}
 	if len(hex) != 8 {
 		t.Errorf("randomHex(4) expected 8 chars, got %d", len(hex))
 	}
@@ -51,8 +54,8 @@ func TestClearAndSet(t *testing.T) {
 
 func TestAuth(t *testing.T) {
 	c := NewVIESClient("test_id", "test_key")
-	auth, ok := c.auth("GET", "https://viesapi.eu/api/test")
-	if !ok {
+	auth, err := c.auth(c.creds, "GET", "https://viesapi.eu/api/test")
+	if err != nil {
 		t.Error("auth failed")
 	}
 	if !strings.Contains(auth, "MAC id=") {
@@ -79,7 +82,8 @@ func TestGetPathSuffix(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		got, ok := c.getPathSuffix(tt.typ, tt.number)
+		got, err := c.getPathSuffix(tt.typ, tt.number)
+		ok := err == nil
 		if ok != tt.ok || (ok && got != tt.want) {
 			t.Errorf("getPathSuffix(%d, %s) = %s, %v; want %s, %v", tt.typ, tt.number, got, ok, tt.want, tt.ok)
 		}
@@ -116,6 +120,7 @@ func TestGetData(t *testing.T) {
 		<countryCode>PL</countryCode>
 		<vatNumber>1234567890</vatNumber>
 		<valid>true</valid>
+		<date>2024-01-15</date>
 	</vies>
 	<error>
 		<code>0</code>
@@ -129,7 +134,7 @@ func TestGetData(t *testing.T) {
 	c := NewVIESClient("test_id", "test_key")
 	c.SetUrl(server.URL)
 
-	data := c.getData("PL7272445205")
+	data, _ := c.getData(context.Background(), "PL7272445205")
 	if data == nil {
 		t.Error("getData returned nil")
 	}
@@ -155,7 +160,7 @@ func TestGetDataError(t *testing.T) {
 	c := NewVIESClient("test_id", "test_key")
 	c.SetUrl(server.URL)
 
-	data := c.getData("PL7272445205")
+	data, _ := c.getData(context.Background(), "PL7272445205")
 	if data != nil {
 		t.Error("getData should return nil on error")
 	}
@@ -205,7 +210,7 @@ func TestGetAccountStatus(t *testing.T) {
 	c := NewVIESClient("test_id", "test_key")
 	c.SetUrl(server.URL)
 
-	status := c.getAccountStatus()
+	status, _ := c.getAccountStatus(context.Background())
 	if status == nil {
 		t.Fatal("getAccountStatus returned nil")
 	}
@@ -222,7 +227,7 @@ func TestGetAccountStatus(t *testing.T) {
 
 func TestGetDataInvalidNumber(t *testing.T) {
 	c := NewVIESClient("", "")
-	data := c.getData("invalid")
+	data, _ := c.getData(context.Background(), "invalid")
 	if data != nil {
 		t.Error("getData should return nil for invalid number")
 	}
@@ -240,7 +245,7 @@ func TestGetInvalidXML(t *testing.T) {
 	c := NewVIESClient("test_id", "test_key")
 	c.SetUrl(server.URL)
 
-	data := c.getData("PL7272445205")
+	data, _ := c.getData(context.Background(), "PL7272445205")
 	if data != nil {
 		t.Error("getData should return nil for invalid XML")
 	}
@@ -257,6 +262,7 @@ func TestViesDataUnmarshal(t *testing.T) {
 		<countryCode>PL</countryCode>
 		<vatNumber>1234567890</vatNumber>
 		<valid>true</valid>
+		<date>2024-01-15</date>
 	</vies>
 	<error>
 		<code>0</code>
@@ -269,8 +275,8 @@ func TestViesDataUnmarshal(t *testing.T) {
 	if err != nil {
 		t.Fatalf("xml.Unmarshal failed: %v", err)
 	}
-	if data.VIES.CountryCode != "PL" {
-		t.Errorf("CountryCode = %s, want PL", data.VIES.CountryCode)
+	if data.VIES == nil || data.VIES.CountryCode == nil || *data.VIES.CountryCode != "PL" {
+		t.Errorf("CountryCode = %v, want PL", data.VIES)
 	}
 }
 
@@ -307,8 +313,8 @@ func TestViesAccountStatusUnmarshal(t *testing.T) {
 
 func TestAuthWithPort(t *testing.T) {
 	c := NewVIESClient("test_id", "test_key")
-	auth, ok := c.auth("GET", "https://viesapi.eu:8443/api/test")
-	if !ok {
+	auth, err := c.auth(c.creds, "GET", "https://viesapi.eu:8443/api/test")
+	if err != nil {
 		t.Error("auth failed with custom port")
 	}
 	if auth == "" {
@@ -329,3 +335,55 @@ func TestGetDateTimeWithTimezone(t *testing.T) {
 		}
 	}
 }
+
+func TestAuthWithClock(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	c := NewVIESClient("test_id", "test_key", WithClock(func() time.Time { return now }))
+	auth, err := c.auth(c.creds, "GET", "https://viesapi.eu/api/test")
+	if err != nil {
+		t.Fatal("auth failed")
+	}
+	if !strings.Contains(auth, `ts="1700000000"`) {
+		t.Errorf("auth = %s, want ts from injected clock", auth)
+	}
+}
+
+func TestClockSkewRetry(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		var ts int64
+		auth := r.Header.Get("Authorization")
+		fmt.Sscanf(auth[strings.Index(auth, `ts="`)+4:], "%d", &ts)
+		if d := time.Now().Unix() - ts; d > 60 || d < -60 {
+			w.Write([]byte(`<result><error><code>54</code><description>Invalid timestamp</description></error></result>`))
+			return
+		}
+		w.Write([]byte(`<result><vies><uid>test-uid</uid><countryCode>PL</countryCode><valid>true</valid><vatNumber>7272445205</vatNumber><date>2024-01-15</date></vies><error><code>0</code></error></result>`))
+	}))
+	defer server.Close()
+
+	c := NewVIESClient("test_id", "test_key", WithClock(func() time.Time {
+		return time.Now().Add(-10 * time.Minute)
+	}))
+	c.SetUrl(server.URL)
+
+	data, err := c.GetVIESData("PL7272445205")
+	if err != nil {
+		t.Fatalf("GetVIESData returned error: %v", err)
+	}
+	if data.UID != "test-uid" || hits != 2 {
+		t.Errorf("uid = %s, hits = %d; want test-uid, 2", data.UID, hits)
+	}
+	if skew := c.ClockSkew(); skew < 9*time.Minute || skew > 11*time.Minute {
+		t.Errorf("ClockSkew() = %v, want about 10m", skew)
+	}
+
+	// the correction is kept for subsequent requests
+	if _, err := c.GetVIESData("PL7272445205"); err != nil {
+		t.Fatalf("GetVIESData returned error: %v", err)
+	}
+	if hits != 3 {
+		t.Errorf("hits = %d, want 3", hits)
+	}
+}
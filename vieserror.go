@@ -6,21 +6,26 @@ type Error struct{}
 // Get error message
 func (e *Error) message(code int) string {
 
-	if code < CLI_CONNECT || code > CLI_INPUT {
+	if code < CLI_CONNECT || code > CLI_CIRCUIT_OPEN {
 		return ""
 	}
 	return _codes[code]
 }
 
 var _codes = map[int]string{
-	CLI_CONNECT:    "Failed to connect to the VIES API service",
-	CLI_RESPONSE:   "VIES API service response has invalid format",
-	CLI_NUMBER:     "Invalid number type",
-	CLI_NIP:        "NIP is invalid",
-	CLI_EUVAT:      "EU VAT ID is invalid",
-	CLI_EXCEPTION:  "Function generated an exception",
-	CLI_DATEFORMAT: "Date has an invalid format",
-	CLI_INPUT:      "Invalid input parameter",
+	CLI_CONNECT:      "Failed to connect to the VIES API service",
+	CLI_RESPONSE:     "VIES API service response has invalid format",
+	CLI_NUMBER:       "Invalid number type",
+	CLI_NIP:          "NIP is invalid",
+	CLI_EUVAT:        "EU VAT ID is invalid",
+	CLI_EXCEPTION:    "Function generated an exception",
+	CLI_DATEFORMAT:   "Date has an invalid format",
+	CLI_INPUT:        "Invalid input parameter",
+	CLI_COUNTRY:      "Country is not an EU VIES member state",
+	CLI_AUTH:         "VIES API service rejected the credentials",
+	CLI_RATE_LIMIT:   "VIES API service request limit exceeded",
+	CLI_CREDENTIALS:  "Failed to get credentials",
+	CLI_CIRCUIT_OPEN: "Member state service is unavailable, lookups suspended",
 }
 
 const (
@@ -104,4 +109,9 @@ const (
 	CLI_EXCEPTION
 	CLI_DATEFORMAT
 	CLI_INPUT
+	CLI_COUNTRY
+	CLI_AUTH
+	CLI_RATE_LIMIT
+	CLI_CREDENTIALS
+	CLI_CIRCUIT_OPEN
 )